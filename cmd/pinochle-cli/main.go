@@ -0,0 +1,329 @@
+// pinochle-cli plays a full game of pinochle in the terminal against three AI players.
+// You sit as player 0 and partner with the AI in seat 2, use -seed to replay the same deals.
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/mzimmerman/sdzpinochle"
+	. "github.com/mzimmerman/sdzpinochle/engine"
+)
+
+var (
+	seed     = flag.Int64("seed", time.Now().UnixNano(), "seed for shuffling, the same seed deals the same hands")
+	debug    = flag.Bool("debug", false, "log the engine's debug messages")
+	record   = flag.String("record", "", "file to write the game's record to when it finishes, see pinochle-replay")
+	rules    = flag.String("rules", StandardRules.Name, "rules to play by - standard, redeal, long, passing, concede, loose, nines, moon or declare, the AI doesn't play double")
+	counters = flag.String("counters", "one", "what counters score - one for aces, tens and kings, ten for 10 each or classic for 11, 10, 4, 3 and 2, the bids, meld and game scale to match")
+)
+
+var in = bufio.NewReader(os.Stdin)
+
+func init() {
+	gob.Register(new(terminal))
+}
+
+// recordFile keeps the record of the finished game in a file
+type recordFile string
+
+func (f recordFile) KeepRecord(game *Game) error {
+	return ioutil.WriteFile(string(f), []byte(game.Record.String()), 0644)
+}
+
+// terminal is the Player for the person at the keyboard, it answers requests by prompting on stdin
+type terminal struct {
+	RealHand *Hand
+	PlayerImpl
+}
+
+func (t *terminal) MarshalJSON() ([]byte, error) {
+	return json.Marshal("You")
+}
+
+func (t *terminal) Hand() *Hand {
+	return t.RealHand
+}
+
+func (t *terminal) SetHand(env *Env, game *Game, h Hand, dealer, playerid uint8) {
+	hand := make(Hand, len(h))
+	copy(hand, h)
+	t.RealHand = &hand
+	t.Playerid = playerid
+	t.Tell(env, game, CreateDeal(hand, playerid, dealer))
+}
+
+func (t *terminal) Tell(env *Env, game *Game, action *Action) *Action {
+	switch action.Type {
+	case "Deal":
+		fmt.Printf("\nNew hand, player %d deals\n", action.Dealer)
+		fmt.Printf("Your hand - %s\n", action.Hand)
+	case "RedealRequest":
+		if action.Playerid == t.Playerid {
+			return t.redeal()
+		}
+	case "Bid":
+		if action.Playerid == t.Playerid {
+			return t.bid(game)
+		}
+		if action.Bid == 0 {
+			fmt.Printf("Player %d passed\n", action.Playerid)
+		} else {
+			fmt.Printf("Player %d bid %d\n", action.Playerid, action.Bid)
+		}
+	case "Trump":
+		if action.Playerid == t.Playerid {
+			return t.trump(game)
+		}
+		fmt.Printf("Player %d named %s trump\n", action.Playerid, action.Trump)
+	case "Moon":
+		fmt.Printf("Player %d named %s trump and is shooting the moon\n", action.Playerid, action.Trump)
+	case "Throwin":
+		fmt.Printf("Player %d threw in\n", action.Playerid)
+	case "Pass":
+		if action.Playerid == t.Playerid {
+			return t.pass(game)
+		}
+		fmt.Printf("Player %d passed you %s\n", action.Playerid, action.Hand)
+	case "MeldRequest":
+		return t.declare(action)
+	case "Meld":
+		fmt.Printf("Player %d melds %d with %s\n", action.Playerid, action.Amount, action.Hand)
+		if len(action.Melds) > 0 {
+			fmt.Printf("  %s\n", action.Melds)
+		}
+	case "PlayRequest":
+		return t.play(game, action)
+	case "Play":
+		fmt.Printf("Player %d played %s\n", action.Playerid, action.PlayedCard)
+	case "Trick":
+		fmt.Printf("Trick %s\n", &game.Trick)
+	case "Message":
+		fmt.Println(action.Message)
+	case "Error":
+		fmt.Printf("Not allowed - %s\n", action.Message)
+	case "Score":
+		if action.GameOver {
+			if action.Win {
+				fmt.Println("Game over, your team won!")
+			} else {
+				fmt.Println("Game over, your team lost")
+			}
+		}
+	}
+	return nil
+}
+
+func (t *terminal) redeal() *Action {
+	for {
+		switch line := prompt(fmt.Sprintf("Your hand - %s can be thrown back, call for a redeal (Y or N)", t.RealHand)); line {
+		case "Y":
+			return CreateRedeal(t.Playerid)
+		case "N":
+			return CreateKeep(t.Playerid)
+		}
+	}
+}
+
+func (t *terminal) bid(game *Game) *Action {
+	fmt.Printf("Your hand - %s\n", t.RealHand)
+	for {
+		line := prompt(fmt.Sprintf("High bid is %d by player %d, your bid (0 to pass)", game.HighBid, game.HighPlayer))
+		bid, err := strconv.ParseUint(line, 10, 16)
+		switch {
+		case err != nil:
+			fmt.Printf("%s is not a number\n", line)
+		case bid != 0 && uint16(bid) < game.HighBid+game.Rules.Raise():
+			fmt.Printf("You have to bid at least %d or pass\n", game.HighBid+game.Rules.Raise())
+		default:
+			return CreateBid(uint16(bid), t.Playerid)
+		}
+	}
+}
+
+func (t *terminal) trump(game *Game) *Action {
+	fmt.Printf("You won the bid at %d, your hand - %s\n", game.HighBid, t.RealHand)
+	for _, suit := range Suits {
+		if game.Rules.DeclareMeld { // finding it is up to you
+			break
+		}
+		meld, _ := t.RealHand.MeldWith(suit, &game.Rules.Meld)
+		fmt.Printf("  %s - %d meld\n", suit, meld)
+	}
+	question := "Name trump (S, H, C or D) or throwin"
+	if game.Rules.MoonBonus > 0 {
+		question = fmt.Sprintf("Name trump (S, H, C or D), MOON and trump to take every trick for %d or throwin", game.Rules.MoonBonus)
+	}
+	for {
+		line := prompt(question)
+		if line == "THROWIN" {
+			return CreateThrowin(t.Playerid)
+		}
+		moon := game.Rules.MoonBonus > 0 && strings.HasPrefix(line, "MOON ")
+		if moon {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "MOON "))
+		}
+		var trump Suit
+		if err := json.Unmarshal([]byte(strconv.Quote(line)), &trump); err != nil {
+			fmt.Printf("%s is not a suit\n", line)
+			continue
+		}
+		if moon {
+			return CreateMoon(trump, t.Playerid)
+		}
+		return CreateTrump(trump, t.Playerid)
+	}
+}
+
+func (t *terminal) pass(game *Game) *Action {
+	count := int(game.Rules.PassCards)
+	to := "your partner"
+	if game.HighPlayer == t.Playerid {
+		to = "back to your partner"
+	}
+	fmt.Printf("Trump is %s, your hand - %s\n", game.Trump, t.RealHand)
+passLoop:
+	for {
+		line := prompt(fmt.Sprintf("Pass %d cards %s, like AS TD 9H", count, to))
+		fields := strings.Fields(line)
+		if len(fields) != count {
+			fmt.Printf("You have to pass %d cards\n", count)
+			continue
+		}
+		hand := append(Hand(nil), *t.RealHand...)
+		pass := make(Hand, count)
+		for x := range fields {
+			if err := json.Unmarshal([]byte(strconv.Quote(fields[x])), &pass[x]); err != nil {
+				fmt.Printf("%s is not a card\n", fields[x])
+				continue passLoop
+			}
+			if !hand.Remove(pass[x]) {
+				fmt.Printf("You don't have %s to pass\n", fields[x])
+				continue passLoop
+			}
+		}
+		return CreatePass(pass, t.Playerid)
+	}
+}
+
+func (t *terminal) declare(action *Action) *Action {
+	fmt.Printf("Trump is %s, your hand - %s\n", action.Trump, t.RealHand)
+declareLoop:
+	for {
+		fields := strings.Fields(prompt("Show your meld, like JD QS KS QS, or nothing if you have none"))
+		hand := append(Hand(nil), *t.RealHand...)
+		meld := make(Hand, len(fields))
+		for x := range fields {
+			if err := json.Unmarshal([]byte(strconv.Quote(fields[x])), &meld[x]); err != nil {
+				fmt.Printf("%s is not a card\n", fields[x])
+				continue declareLoop
+			}
+			if !hand.Remove(meld[x]) {
+				fmt.Printf("You don't have %s to show\n", fields[x])
+				continue declareLoop
+			}
+		}
+		return CreateMeld(meld, 0, t.Playerid)
+	}
+}
+
+func (t *terminal) play(game *Game, action *Action) *Action {
+	fmt.Printf("Trick %s\n", &game.Trick)
+	fmt.Printf("Your hand - %s\n", t.RealHand)
+	if action.Lead != NASuit && len(action.Legal) > 0 {
+		fmt.Printf("You can play - %s\n", action.Legal)
+	}
+	question := fmt.Sprintf("Trump is %s, play a card", action.Trump)
+	if game.State == StateMeld {
+		question += " or concede"
+	}
+	for {
+		line := prompt(question)
+		if line == "CONCEDE" && game.State == StateMeld {
+			return CreateThrowin(t.Playerid)
+		}
+		var card Card
+		if err := json.Unmarshal([]byte(strconv.Quote(line)), &card); err != nil {
+			fmt.Printf("%s is not a card, try something like AS or 9D\n", line)
+			continue
+		}
+		if !IsCardInHand(card, *t.RealHand) {
+			fmt.Printf("You don't have %s\n", card)
+			continue
+		}
+		if !ValidPlayWith(card, action.WinningCard, action.Lead, t.RealHand, action.Trump, &game.Rules.Play) {
+			fmt.Printf("You can't play %s, %s\n", card, playRule(&game.Rules.Play))
+			continue
+		}
+		return CreatePlay(card, t.Playerid)
+	}
+}
+
+// playRule says what rules has a player play to a trick
+func playRule(rules *PlayRules) string {
+	rule := "follow suit"
+	if rules.MustTrump {
+		rule += ", trump if you can't"
+	}
+	switch {
+	case rules.MustHead && rules.MustOvertrump:
+		rule += " and win the trick if you can"
+	case rules.MustHead:
+		rule += " and win the trick if you can following suit"
+	case rules.MustOvertrump:
+		rule += " and win the trick if you can when you trump"
+	}
+	return rule
+}
+
+// prompt reads one line of input, upper cased so cards and suits can be typed either way
+func prompt(question string) string {
+	fmt.Printf("%s: ", question)
+	line, err := in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		fmt.Println()
+		os.Exit(0)
+	} else if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "Error reading input - %v\n", err)
+		os.Exit(1)
+	}
+	return strings.ToUpper(strings.TrimSpace(line))
+}
+
+func main() {
+	flag.Parse()
+	gameRules, ok := RulePresets[*rules]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown rules %s\n", *rules)
+		os.Exit(2)
+	}
+	counterValues, ok := CounterPresets[*counters]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown counters %s\n", *counters)
+		os.Exit(2)
+	}
+	gameRules = gameRules.ScoredWith(counterValues)
+	fmt.Printf("Playing %s rules with seed %d, you are player 0 and your partner is player 2\n", gameRules.Name, *seed)
+	env := &Env{
+		Store:  NewMemoryStore(),
+		Logger: StdLogger{Debug: *debug},
+	}
+	if *record != "" {
+		env.Records = recordFile(*record)
+	}
+	game := NewGame(4, *seed, gameRules)
+	game.Players[0] = new(terminal)
+	if _, err := game.NextHand(env); err != nil {
+		fmt.Fprintf(os.Stderr, "Error - %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,58 @@
+// pinochle-replay plays game records back through the engine and checks that they reach the same scores.
+// Records are written by pinochle-cli -record and by sdzpinochle-server -data in its records directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	. "github.com/mzimmerman/sdzpinochle/engine"
+)
+
+var (
+	show  = flag.Bool("show", false, "print each record before replaying it")
+	debug = flag.Bool("debug", false, "log the engine's debug messages")
+)
+
+func replay(env *Env, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	record, err := ParseRecord(file)
+	if err != nil {
+		return err
+	}
+	if *show {
+		fmt.Print(record)
+	}
+	if err = Replay(env, record); err != nil {
+		return err
+	}
+	fmt.Printf("%s - replayed %d hands to %v\n", path, len(record.Hands), record.Score)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: pinochle-replay [-show] record...\n")
+		os.Exit(2)
+	}
+	env := &Env{
+		Store:  NewMemoryStore(),
+		Logger: StdLogger{Debug: *debug},
+	}
+	failed := false
+	for _, path := range flag.Args() {
+		if err := replay(env, path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s - %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,239 @@
+// pinochle-sim plays games between two AI strategies, one per team, and reports how each team did.
+// A strategy is a comma separated list of settings, for example -team1 bid=2,throwin=12,plays=500000
+//
+//	bid     - added to every bid the AI calculates
+//	throwin - throw in after taking the bid when the hand was worth less than this
+//	plays   - how many plays to try searching for each card to play
+//	samples - how many deals of the unseen cards share those plays, searched in parallel
+//	think   - also stop searching after this long, the results then depend on the machine
+//	player  - ai to search each deal on its own or ismcts for information set Monte Carlo tree search, to pit them head to head
+//	explore - how much ismcts favors the cards it has tried less
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/mzimmerman/sdzpinochle"
+	. "github.com/mzimmerman/sdzpinochle/engine"
+)
+
+var (
+	games    = flag.Int("games", 10, "number of games to play")
+	seed     = flag.Int64("seed", 1, "seed for the first game, game n uses seed+n so every run deals the same hands")
+	team0    = flag.String("team0", "", "strategy for players 0 and 2")
+	team1    = flag.String("team1", "", "strategy for players 1 and 3")
+	plays    = flag.Uint("plays", 200000, "plays searched for each card by strategies that don't set plays")
+	verbose  = flag.Bool("v", false, "show the AI's reasoning")
+	rules    = flag.String("rules", StandardRules.Name, "rules to play by - standard, redeal, long, passing, concede, loose, nines, moon or declare, the AI doesn't play double")
+	counters = flag.String("counters", "one", "what counters score - one for aces, tens and kings, ten for 10 each or classic for 11, 10, 4, 3 and 2, the bids, meld and game scale to match")
+)
+
+type teamStats struct {
+	wins     int
+	bids     int // hands this team took the bid
+	bidTotal int
+	made     int
+	throwins int
+	concedes int // bids thrown in after the meld was shown
+	meld     int
+	counters int
+}
+
+// team is how the seats on one team play
+type team struct {
+	Strategy
+	player      string  // ai or ismcts
+	exploration float64 // for ismcts, 0 is its default
+}
+
+func (t team) newPlayer() Player {
+	if t.player == "ismcts" {
+		m := NewISMCTS(t.Strategy)
+		m.Exploration = t.exploration
+		return m
+	}
+	return NewAI(t.Strategy)
+}
+
+func (t team) String() string {
+	return fmt.Sprintf("%s %+v", t.player, t.Strategy)
+}
+
+// observer sits in one seat and records every hand from the game state when the hand is scored
+type observer struct {
+	Player
+	played int // hands that were played out, not thrown in
+	teams  []teamStats
+}
+
+func (o *observer) Tell(env *Env, game *Game, action *Action) *Action {
+	if action.Type == "Score" {
+		o.record(game, action)
+	}
+	return o.Player.Tell(env, game, action)
+}
+
+func (o *observer) record(game *Game, action *Action) {
+	if o.teams == nil {
+		o.teams = make([]teamStats, len(game.Score))
+	}
+	team := game.HighPlayer % uint8(len(o.teams))
+	bidder := &o.teams[team]
+	bidder.bids++
+	bidder.bidTotal += int(game.HighBid)
+	if game.State == StateTrump { // scored without being played, the bidder threw in
+		bidder.throwins++
+	} else if game.State == StateMeld { // conceded after seeing the meld
+		bidder.concedes++
+	} else {
+		o.played++
+		if game.HighBid <= game.Meld[team]+game.Counters[team] {
+			bidder.made++
+		}
+		for x := range o.teams {
+			o.teams[x].meld += int(game.Meld[x])
+			o.teams[x].counters += int(game.Counters[x])
+		}
+	}
+	if action.GameOver {
+		winner := int(o.Team())
+		if !action.Win { // the bidders win when they go out, otherwise the best of the rest
+			bidders := int(game.HighPlayer) % len(o.teams)
+			if bidders != winner && action.Score[bidders] >= game.Rules.GameTarget {
+				winner = bidders
+			} else {
+				best := -1
+				for x := range action.Score {
+					if x != winner && (best == -1 || action.Score[x] > action.Score[best]) {
+						best = x
+					}
+				}
+				winner = best
+			}
+		}
+		o.teams[winner].wins++
+		fmt.Printf("Team%d won with %d, scores %v\n", winner, action.Score[winner], action.Score)
+	}
+}
+
+func parseTeam(spec string) (t team, err error) {
+	t.player = "ai"
+	t.Plays = *plays
+	if spec == "" {
+		return
+	}
+	for _, setting := range strings.Split(spec, ",") {
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			return t, fmt.Errorf("%s is not name=value", setting)
+		}
+		switch kv[0] {
+		case "bid":
+			var offset int64
+			offset, err = strconv.ParseInt(kv[1], 10, 8)
+			t.BidOffset = int8(offset)
+		case "throwin":
+			var below uint64
+			below, err = strconv.ParseUint(kv[1], 10, 8)
+			t.ThrowinBelow = uint8(below)
+		case "plays":
+			var count uint64
+			count, err = strconv.ParseUint(kv[1], 10, 0)
+			t.Plays = uint(count)
+		case "samples":
+			var count int64
+			count, err = strconv.ParseInt(kv[1], 10, 0)
+			t.Samples = int(count)
+		case "think":
+			t.ThinkTime, err = time.ParseDuration(kv[1])
+		case "player":
+			if kv[1] != "ai" && kv[1] != "ismcts" {
+				err = fmt.Errorf("unknown player %s", kv[1])
+			}
+			t.player = kv[1]
+		case "explore":
+			t.exploration, err = strconv.ParseFloat(kv[1], 64)
+		default:
+			err = fmt.Errorf("unknown setting %s", kv[0])
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(of)
+}
+
+func average(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+func main() {
+	flag.Parse()
+	var teams [2]team
+	for x, spec := range []string{*team0, *team1} {
+		var err error
+		if teams[x], err = parseTeam(spec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in -team%d - %v\n", x, err)
+			os.Exit(2)
+		}
+	}
+	gameRules, ok := RulePresets[*rules]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown rules %s\n", *rules)
+		os.Exit(2)
+	}
+	counterValues, ok := CounterPresets[*counters]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown counters %s\n", *counters)
+		os.Exit(2)
+	}
+	gameRules = gameRules.ScoredWith(counterValues)
+	if !*verbose {
+		LogOutput = ioutil.Discard
+	}
+	env := &Env{
+		Store:  NewMemoryStore(),
+		Logger: StdLogger{},
+	}
+	obs := new(observer)
+	for x := 0; x < *games; x++ {
+		game := NewGame(4, *seed+int64(x), gameRules)
+		for y := range game.Players {
+			game.Players[y] = teams[y%2].newPlayer()
+		}
+		obs.Player = game.Players[0]
+		game.Players[0] = obs
+		if _, err := game.NextHand(env); err != nil {
+			fmt.Fprintf(os.Stderr, "Error - %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("\nPlayed %d games with seed %d, %d hands played out\n", *games, *seed, obs.played)
+	for x, ts := range obs.teams {
+		fmt.Printf("Team%d %s\n", x, teams[x%2])
+		fmt.Printf("  win rate       %5.1f%%\n", percent(ts.wins, *games))
+		fmt.Printf("  bids taken     %5d\n", ts.bids)
+		fmt.Printf("  average bid    %5.1f\n", average(ts.bidTotal, ts.bids))
+		fmt.Printf("  bids made      %5.1f%%\n", percent(ts.made, ts.bids-ts.throwins-ts.concedes))
+		fmt.Printf("  throw ins      %5.1f%%\n", percent(ts.throwins, ts.bids))
+		fmt.Printf("  concessions    %5.1f%%\n", percent(ts.concedes, ts.bids))
+		fmt.Printf("  meld/hand      %5.1f\n", average(ts.meld, obs.played))
+		fmt.Printf("  counters/hand  %5.1f\n", average(ts.counters, obs.played))
+	}
+}
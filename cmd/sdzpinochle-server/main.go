@@ -0,0 +1,197 @@
+// sdzpinochle-server serves the pinochle web client from plain net/http so a game can be
+// played without App Engine.  Actions travel both ways over a websocket at /ws, games and clients
+// are kept in memory, or in a directory when -data is given.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+	. "github.com/mzimmerman/sdzpinochle"
+	. "github.com/mzimmerman/sdzpinochle/engine"
+)
+
+const (
+	cookieName = "sdzpinochle"
+)
+
+var (
+	addr   = flag.String("addr", ":8080", "address to listen on")
+	static = flag.String("static", "server", "directory holding index.html and cards/")
+	data   = flag.String("data", "", "directory to keep games, clients and finished game records in, memory only if empty")
+	secret = flag.String("secret", "sdzpinochle", "key used to sign the session cookie")
+	debug  = flag.Bool("debug", false, "log debug messages")
+)
+
+var store *sessions.CookieStore
+
+// the engine is not safe to run concurrently on the same game, App Engine used a single AI instance for the same reason
+var processMutex sync.Mutex
+
+func main() {
+	flag.Parse()
+	store = sessions.NewCookieStore([]byte(*secret))
+	store.Options = &sessions.Options{
+		Path:   "/",
+		MaxAge: 3600, // keep the cookie for one hour
+	}
+	env := &Env{
+		Notifier: newSocketNotifier(),
+		Logger:   StdLogger{Debug: *debug},
+	}
+	if *data == "" {
+		env.Store = NewMemoryStore()
+	} else {
+		fs, err := NewFileStore(*data)
+		if err != nil {
+			log.Fatalf("Unable to open %s - %v", *data, err)
+		}
+		env.Store = fs
+		env.Records = fs
+	}
+	http.HandleFunc("/connect", func(w http.ResponseWriter, r *http.Request) { connect(env, w, r) })
+	http.HandleFunc("/receive", func(w http.ResponseWriter, r *http.Request) { receive(env, w, r) })
+	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) { serveWs(env, w, r) })
+	http.Handle("/cards/", http.FileServer(http.Dir(*static)))
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(*static, "index.html"))
+	})
+	go remind(env)
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// getClient loads the client identified by the session cookie
+func getClient(env *Env, r *http.Request) (*Client, error) {
+	cookie, _ := store.Get(r, cookieName)
+	client := new(Client)
+	var ok bool
+	client.Id, ok = cookie.Values["ClientId"].(int64)
+	if !ok || client.Id == 0 {
+		return nil, errors.New("Tried to receive a message from an unknown client")
+	}
+	if err := env.Store.GetClient(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func httpError(env *Env, w http.ResponseWriter, err error) {
+	env.Errorf("Error - %v", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "Error - %v", err)
+}
+
+func connect(env *Env, w http.ResponseWriter, r *http.Request) {
+	processMutex.Lock()
+	defer processMutex.Unlock()
+	client := new(Client)
+	cookie, _ := store.Get(r, cookieName)
+	var ok bool
+	client.Id, ok = cookie.Values["ClientId"].(int64)
+	if ok && client.Id != 0 {
+		env.Debugf("connect - Getting client %d", client.Id)
+		if err := env.Store.GetClient(client); err == ErrNoSuchEntity {
+			client = new(Client)
+		} else if err != nil {
+			httpError(env, w, err)
+			return
+		}
+	}
+	client.Connected = false // the client is only connecting now, it still has to open /ws
+	if client.Id == 0 {
+		if err := env.Store.PutClient(client); err != nil { // assigns the id
+			httpError(env, w, err)
+			return
+		}
+	}
+	client.Token = client.GetId()
+	if err := env.Store.PutClient(client); err != nil {
+		httpError(env, w, err)
+		return
+	}
+	cookie.Values["ClientId"] = client.Id
+	cookie.Save(r, w)
+	w.Header().Set("Content-type", "application/json")
+	rj, _ := json.Marshal(client.Token)
+	fmt.Fprintf(w, "%s", rj)
+}
+
+func receive(env *Env, w http.ResponseWriter, r *http.Request) {
+	client, err := getClient(env, r)
+	if err != nil {
+		httpError(env, w, err)
+		return
+	}
+	action := new(Action)
+	if err = json.NewDecoder(r.Body).Decode(action); err != nil {
+		httpError(env, w, err)
+		return
+	}
+	env.Debugf("Received %s", action)
+	processMutex.Lock()
+	defer processMutex.Unlock()
+	if err = processAction(env, client.Id, action); err != nil {
+		httpError(env, w, err)
+		return
+	}
+	fmt.Fprintf(w, "Success")
+}
+
+// processAction runs action for the client against the table it's sitting at, the caller must hold processMutex
+func processAction(env *Env, clientId int64, action *Action) error {
+	// load the client fresh, another request may have changed it
+	client := &Client{Id: clientId}
+	if err := env.Store.GetClient(client); err != nil {
+		return err
+	}
+	var game *Game
+	if client.TableId != 0 {
+		game = &Game{Id: client.TableId}
+		err := env.Store.GetGame(game)
+		if err == ErrNoSuchEntity {
+			game = nil
+		} else if err != nil {
+			return err
+		}
+	}
+	_, err := game.ProcessAction(env, client, action)
+	return err
+}
+
+// remind retells stale games what they are waiting on and releases humans from games that were abandoned
+func remind(env *Env) {
+	for range time.Tick(time.Minute) {
+		processMutex.Lock()
+		games, err := env.Store.StaleGames(time.Now().Add(-time.Minute))
+		if err != nil {
+			env.Errorf("Error - %v", err)
+		}
+		for _, game := range games {
+			game.Retell(env)
+			if game.Updated.Before(time.Now().Add(-30 * time.Minute)) {
+				for _, player := range game.Players {
+					if human, ok := player.(*Human); ok {
+						human.Client.TableId = 0
+						if err := env.Store.PutClient(human.Client); err != nil {
+							env.Errorf("Error - %v", err)
+						}
+					}
+				}
+			}
+		}
+		processMutex.Unlock()
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/mzimmerman/sdzpinochle"
+	. "github.com/mzimmerman/sdzpinochle/engine"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+)
+
+var errNotConnected = errors.New("client has no open websocket")
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// socket is one client's open websocket, everything written to it goes through send so only one goroutine writes
+type socket struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// socketNotifier is the ClientNotifier for clients connected through /ws
+type socketNotifier struct {
+	mutex   sync.Mutex
+	sockets map[int64]*socket
+}
+
+func newSocketNotifier() *socketNotifier {
+	return &socketNotifier{sockets: make(map[int64]*socket)}
+}
+
+// Notify sends while holding the mutex, register and unregister close send under it so it is never closed mid send
+func (sn *socketNotifier) Notify(client *Client, message []byte) error {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+	s, ok := sn.sockets[client.Id]
+	if !ok {
+		return errNotConnected
+	}
+	select {
+	case s.send <- message:
+		return nil
+	default:
+		return errors.New("client is not reading, dropping message")
+	}
+}
+
+// register makes s the client's socket, closing any socket it had open before (e.g. another tab)
+func (sn *socketNotifier) register(id int64, s *socket) {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+	if old, ok := sn.sockets[id]; ok {
+		close(old.send)
+	}
+	sn.sockets[id] = s
+}
+
+// unregister reports whether s was still the client's socket
+func (sn *socketNotifier) unregister(id int64, s *socket) bool {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+	if sn.sockets[id] != s {
+		return false // replaced by a newer connection
+	}
+	delete(sn.sockets, id)
+	close(s.send)
+	return true
+}
+
+func (s *socket) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		s.conn.Close()
+	}()
+	for {
+		select {
+		case message, ok := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				s.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := s.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveWs carries Actions both ways for the client identified by the session cookie set in /connect
+func serveWs(env *Env, w http.ResponseWriter, r *http.Request) {
+	client, err := getClient(env, r)
+	if err != nil {
+		httpError(env, w, err)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		env.Errorf("Error - %v", err)
+		return
+	}
+	s := &socket{conn: conn, send: make(chan []byte, 1000)}
+	notifier := env.Notifier.(*socketNotifier)
+	notifier.register(client.Id, s)
+	go s.writePump()
+
+	err = func() error {
+		processMutex.Lock()
+		defer processMutex.Unlock()
+		if err := env.Store.GetClient(client); err != nil {
+			return err
+		}
+		return client.SetConnected(env, true)
+	}()
+	if err != nil {
+		env.Errorf("Error - %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	for {
+		action := new(Action)
+		if err := conn.ReadJSON(action); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				env.Errorf("Error - %v", err)
+			}
+			break
+		}
+		env.Debugf("Received %s", action)
+		err := func() error {
+			processMutex.Lock()
+			defer processMutex.Unlock()
+			return processAction(env, client.Id, action)
+		}()
+		if err != nil {
+			env.Errorf("Error - %v", err)
+		}
+	}
+	if !notifier.unregister(client.Id, s) {
+		return // the client already reconnected on another socket
+	}
+	processMutex.Lock()
+	defer processMutex.Unlock()
+	if err := env.Store.GetClient(client); err != nil {
+		env.Errorf("Error - %v", err)
+		return
+	}
+	if err := client.SetConnected(env, false); err != nil {
+		env.Errorf("Error - %v", err)
+	}
+}
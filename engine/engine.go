@@ -0,0 +1,2295 @@
+// Package engine runs a game of pinochle independent of where it is hosted.
+// Storage, client notification and logging are supplied through an Env so that
+// the same state machine can run on App Engine, a plain net/http server or in a test.
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/mzimmerman/sdzpinochle"
+)
+
+const (
+	StateNew     = "new"
+	StateBid     = "bid"
+	StateTrump   = "trump"
+	StateMeld    = "meld"
+	StatePlay    = "play"
+	StatePass    = "pass"
+	StateRedeal  = "redeal"
+	StateDeclare = "declare"
+	StateBury    = "bury"
+	None         = uint8(0)
+	Unknown      = uint8(3)
+)
+
+// ErrNoSuchEntity is returned by a GameStore when the requested Game or Client does not exist
+var ErrNoSuchEntity = errors.New("engine: no such entity")
+
+// ErrComputerSeating is returned by NextHand when a computer player is seated anywhere but four handed with a single deck,
+// the HandTracker and the searches only follow that game
+var ErrComputerSeating = errors.New("engine: computer players only play four handed with a single deck")
+
+// GameStore persists games and clients between actions
+type GameStore interface {
+	GetGame(game *Game) error // loads the game identified by game.Id
+	PutGame(game *Game) error // saves the game, assigning game.Id if it is 0
+	DeleteGame(game *Game) error
+	GetClient(client *Client) error               // loads the client identified by client.Id
+	PutClient(client *Client) error               // saves the client, assigning client.Id if it is 0
+	NewGames(limit int) ([]*Game, error)          // games that are waiting for players
+	StaleGames(before time.Time) ([]*Game, error) // games that have not been updated since before
+}
+
+// ClientNotifier delivers a JSON message to a connected client
+type ClientNotifier interface {
+	Notify(client *Client, message []byte) error
+}
+
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// StdLogger writes to the standard log package, debug messages are only written when Debug is set
+type StdLogger struct {
+	Debug bool
+}
+
+func (l StdLogger) Debugf(format string, args ...interface{}) {
+	if l.Debug {
+		log.Printf("DEBUG - "+format, args...)
+	}
+}
+
+func (l StdLogger) Errorf(format string, args ...interface{}) {
+	log.Printf("ERROR - "+format, args...)
+}
+
+// Env is everything the engine needs from its host to process an action
+type Env struct {
+	Store    GameStore
+	Notifier ClientNotifier
+	Records  RecordKeeper // optional, given every finished game
+	Logger
+}
+
+var Hands = make(chan Hand, 1000)
+
+var htstack = new(HTStack)
+
+func init() {
+	gob.Register(new(AI))
+	gob.Register(new(ISMCTS))
+	//gob.Register(AI{})
+	gob.Register(new(Human))
+	gob.Register(new(replayer))
+	//gob.Register(Human{})
+}
+
+func getHand() Hand {
+	var h Hand
+	select {
+	case h = <-Hands:
+		h = h[:0] // empty the slice
+	default:
+		h = make(Hand, 0, 24)
+	}
+	return h
+}
+
+func getHT(owner uint8) (*HandTracker, error) {
+	return htstack.Pop()
+}
+
+type CardMap [25]uint8
+
+func (cm *CardMap) inc(x Card) {
+	if cm[x] == Unknown {
+		cm[x] = 1
+	} else {
+		cm[x]++
+	}
+}
+
+func (cm *CardMap) dec(x Card) {
+	//Log(4, "Before dec, %s = %d", x, cm[x])
+	if cm[x] == 0 {
+		//Log(4, "Attempting to decrement %s from %d", card(x), cm[x])
+		panic("Cannot decrement past 0")
+	}
+	if cm[x] != Unknown {
+		cm[x]--
+	}
+	//Log(4, "After dec, %s = %d", x, cm[x])
+}
+
+func (ht *HandTracker) reset(owner uint8) {
+	ht.Owner = owner
+	for x := 0; x < len(ht.PlayedCards); x++ {
+		for y := uint8(0); y < 4; y++ {
+			if y == ht.Owner {
+				ht.PlayedCards[x] = None
+				ht.Cards[y][x] = None
+			} else {
+				ht.Cards[y][x] = Unknown
+			}
+			ht.Max[y][x] = 2
+		}
+	}
+	ht.Passed = [4]int8{}
+	ht.PlayCount = 0
+	ht.Trick = new(Trick)
+	ht.Trick.reset()
+}
+
+// HTStack recycles HandTrackers, it is shared by every game in the process so it guards itself
+type HTStack struct {
+	mutex sync.Mutex
+	hts   []*HandTracker
+}
+
+func (hts *HTStack) Push(ht *HandTracker) {
+	hts.mutex.Lock()
+	defer hts.mutex.Unlock()
+	hts.hts = append(hts.hts, ht)
+}
+
+func (hts *HTStack) Pop() (ht *HandTracker, err error) {
+	hts.mutex.Lock()
+	defer hts.mutex.Unlock()
+	//x, a = a[len(a)-1], a[:len(a)-1]
+	l := len(hts.hts) - 1
+	if l < 0 {
+		//memstats := new(runtime.MemStats)
+		//runtime.ReadMemStats(memstats)
+		//Log(4, "MemStats = %#v", memstats)
+		ht = new(HandTracker)
+		ht.Trick = new(Trick)
+		return
+	}
+	ht, hts.hts = hts.hts[l], hts.hts[:l]
+	return
+}
+
+type HandTracker struct {
+	// the fewest of each card each player is holding
+	// None = does not have any of this card
+	// 1 = has at least one of this card
+	// 2 = has two of these cards
+	// Unknown = may have none
+	Cards       [4]CardMap
+	Max         [4]CardMap // the most of each card each player could be holding, see most
+	Passed      [4]int8    // cards each player has been passed less the cards it passed, see handSize
+	PlayedCards CardMap
+	Owner       uint8 // the playerid of the "owning" player
+	Trick       *Trick
+	PlayCount   uint8
+	Play        *PlayRules     // what the players had to play, nil means the StandardRules
+	Counting    *CounterValues // what the tricks score, nil means the StandardRules
+}
+
+func (ht *HandTracker) playRules() *PlayRules {
+	if ht.Play == nil {
+		return &StandardRules.Play
+	}
+	return ht.Play
+}
+
+func (ht *HandTracker) counting() *CounterValues {
+	if ht.Counting == nil {
+		return StandardRules.Counting()
+	}
+	return ht.Counting
+}
+
+func (ht *HandTracker) sum(cardIndex Card) (sum uint8) {
+	sum = ht.PlayedCards[cardIndex]
+	for x := 0; x < len(ht.Cards); x++ {
+		if ht.Cards[x][cardIndex] != Unknown {
+			sum += ht.Cards[x][cardIndex]
+		}
+	}
+	if sum > 2 {
+		Log(ht.Owner, "Summing card %s, sum = %d", cardIndex, sum)
+		ht.Debug()
+		panic("sumthing is wrong, get it?!?!")
+	}
+	return
+}
+
+func (oldht *HandTracker) Copy() (newht *HandTracker, err error) {
+	newht, err = getHT(oldht.Owner)
+	for x := uint8(0); x < uint8(len(oldht.Cards)); x++ {
+		newht.Cards[x] = oldht.Cards[x]
+	}
+	newht.Max = oldht.Max
+	newht.Passed = oldht.Passed
+	newht.PlayedCards = oldht.PlayedCards
+	newht.PlayCount = oldht.PlayCount
+	newht.Play = oldht.Play
+	newht.Counting = oldht.Counting
+	*newht.Trick = *oldht.Trick
+	return
+}
+
+func (ht *HandTracker) Debug() {
+	Log(ht.Owner, "ht.PlayedCards = %v", ht.PlayedCards)
+	for x := 0; x < 4; x++ {
+		Log(ht.Owner, "Player%d - %s", x, ht.Cards[x])
+	}
+	Log(ht.Owner, "PlayCount = %d, Next=%d", ht.PlayCount, ht.Trick.Next)
+	panic("don't call debug")
+}
+
+func (ht *HandTracker) PlayCard(card Card, trump Suit) {
+	//ht.Debug()
+	playerid := ht.Trick.Next
+	//Log(ht.Owner, "In ht.PlayCard for %d-%s on player %d", playerid, card, ht.Owner)
+	val := ht.Cards[playerid][card]
+	if val == None {
+		ht.Debug()
+		Log(ht.Owner, "Player %d does not have card %s, panicking", playerid, card)
+		panic("panic")
+	}
+	ht.PlayedCards.inc(card)
+	ht.remove(playerid, card)
+	if ht.sum(card) > 2 {
+		panic("Cannot play this card, something is wrong")
+	}
+	ht.Trick.PlayCard(card, trump)
+	ht.PlayCount++ // before working anything out, the hand sizes go by it
+	ht.calculateCard(card)
+	rules := ht.playRules()
+	switch {
+	case ht.Trick.leadSuit() == NASuit || trump == NASuit:
+		// do nothing, start of the trick, everything is legal
+	case card.Suit() != ht.Trick.leadSuit() && card.Suit() != trump: // couldn't follow suit, couldn't lay trump
+		if rules.MustTrump {
+			ht.noSuit(playerid, trump)
+		}
+		//if oright == ht && playerid == 1 {
+		//	Log(ht.Owner, "Setting all %s to None for playerid=%d", trump, playerid)
+		//}
+		fallthrough
+	case card.Suit() != ht.Trick.leadSuit(): // couldn't follow suit
+		ht.noSuit(playerid, ht.Trick.leadSuit())
+		//if oright == ht && playerid == 1 {
+		//	Log(ht.Owner, "Setting all %s to None for playerid=%d", trick.leadSuit(), playerid)
+		//}
+	}
+	// only a player the rules made head the trick gives away that it couldn't
+	mustWin := rules.MustHead
+	if card.Suit() != ht.Trick.leadSuit() {
+		mustWin = card.Suit() == trump && rules.MustOvertrump
+	}
+	if playerid != ht.Trick.WinningPlayer && mustWin { // did not win
+		for _, f := range Faces {
+			tempCard := CreateCard(card.Suit(), f)
+			if tempCard.Beats(ht.Trick.winningCard(), trump) {
+				//if oright == ht && playerid == 1 {
+				//Log(ht.Owner, "Setting %s to None for playerid=%d because it could have won", tempCard, playerid)
+				//}
+				ht.Cards[playerid][tempCard] = None
+				ht.calculateCard(tempCard)
+			} else {
+				break
+			}
+		}
+	}
+	//Log(ht.Owner, "Player %d played card %s, PlayCount=%d", playerid, card, ht.PlayCount)
+}
+
+func (cm CardMap) String() string {
+	output := "CardMap={"
+	for x := AS; int8(x) <= AllCards; x++ {
+		if cm[x] == Unknown {
+			continue
+		}
+		if cm[x] == None {
+			output += fmt.Sprintf("%s:%d ", x, 0)
+		} else {
+			output += fmt.Sprintf("%s:%d ", x, cm[x])
+		}
+	}
+	return output + "}"
+}
+
+func (ai *AI) populate() {
+	ai.HT.reset(ai.Playerid)
+	ai.HT.Play = ai.Play
+	ai.HT.Counting = ai.Counting
+	for _, card := range *ai.RealHand {
+		ai.HT.Cards[ai.Playerid].inc(card)
+	}
+	ai.HT.calculateHand(ai.Playerid)
+}
+
+// pass moves cards the owner saw change hands from one player to another
+func (ht *HandTracker) pass(cards Hand, from, to uint8) {
+	for _, card := range cards {
+		ht.remove(from, card)
+		most := ht.most(to, card) + 1
+		if most > 2 {
+			most = 2
+		}
+		ht.set(to, card, ht.least(to, card)+1, most)
+		ht.Passed[from]--
+		ht.Passed[to]++
+		ht.calculateCard(card)
+	}
+}
+
+func (ht *HandTracker) noSuit(playerid uint8, suit Suit) {
+	//Log(ht.Owner, "No suit start on %s", suit)
+	card := CreateCard(suit, Ace)
+	for x := 0; x < 6; x++ {
+		//Log(ht.Owner, "Card=%s", card)
+		ht.Cards[playerid][card] = None
+		ht.calculateCard(card)
+		card++
+	}
+	//Log(ht.Owner, "No suit end")
+}
+
+// calculateHand works out what else is known and returns the fewest cards the player is known to hold
+func (ht *HandTracker) calculateHand(hand uint8) (totalCards uint8) {
+	ht.infer()
+	for x := AS; int8(x) <= AllCards; x++ {
+		totalCards += ht.least(hand, x)
+	}
+	return totalCards
+}
+
+// calculateCard works out what else is known after what's known about the card changed,
+// which can reach every other card through the hand sizes
+func (ht *HandTracker) calculateCard(cardIndex Card) {
+	sum := ht.sum(cardIndex)
+	if sum > 2 {
+		Log(ht.Owner, "htcardset - Card=%s,sum=%d", cardIndex, sum)
+		Log(ht.Owner, "ht.PlayedCards = %s", ht.PlayedCards)
+		for x := 0; x < 4; x++ {
+			Log(ht.Owner, "Player%d - %s", x, ht.Cards[x])
+		}
+		panic("Cannot have more cards than 2 - " + cardIndex.String())
+	}
+	ht.infer()
+}
+
+// least is the fewest of the card the player is known to hold
+func (ht *HandTracker) least(player uint8, card Card) uint8 {
+	if ht.Cards[player][card] == Unknown {
+		return 0
+	}
+	return ht.Cards[player][card]
+}
+
+// most is the most of the card the player could be holding, the owner holds exactly what it knows of
+func (ht *HandTracker) most(player uint8, card Card) uint8 {
+	least := ht.least(player, card)
+	switch {
+	case ht.Cards[player][card] == None:
+		return 0
+	case player == ht.Owner || ht.Max[player][card] < least:
+		return least
+	}
+	return ht.Max[player][card]
+}
+
+// set records that the player holds from least to most of the card
+func (ht *HandTracker) set(player uint8, card Card, least, most uint8) {
+	ht.Max[player][card] = most
+	switch {
+	case most == 0:
+		ht.Cards[player][card] = None
+	case least == 0:
+		ht.Cards[player][card] = Unknown
+	default:
+		ht.Cards[player][card] = least
+	}
+}
+
+// remove takes a copy of the card the player was seen to give up out of what it could be holding
+func (ht *HandTracker) remove(player uint8, card Card) {
+	least, most := ht.least(player, card), ht.most(player, card)
+	if least > 0 {
+		least--
+	}
+	if most > 0 {
+		most--
+	}
+	ht.set(player, card, least, most)
+}
+
+// infer tightens what's known of every player's cards as far as it goes, see bounds
+func (ht *HandTracker) infer() {
+	b := ht.bounds()
+	for player := uint8(0); player < 4; player++ {
+		for card := AS; int8(card) <= AllCards; card++ {
+			ht.set(player, card, b.least[player][card], b.most[player][card])
+		}
+	}
+}
+
+// cardBounds are the fewest and the most of each card each player could be holding, with the cards each has left
+type cardBounds struct {
+	least, most [4]CardMap
+	size        [4]uint8
+}
+
+// bounds returns what the HandTracker knows of the cards worked through until nothing more follows from it,
+// the HandTracker isn't changed.  Every copy of a card not yet played is in someone's hand and every player
+// holds exactly as many cards as it has left, so when only one player can still hold a copy it holds it
+// and when a player's hand is filled by the cards it's known to hold it holds nothing else.
+func (ht *HandTracker) bounds() (b cardBounds) {
+	for player := uint8(0); player < 4; player++ {
+		b.size[player] = ht.handSize(player)
+		for card := AS; int8(card) <= AllCards; card++ {
+			b.least[player][card] = ht.least(player, card)
+			b.most[player][card] = ht.most(player, card)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for card := AS; int8(card) <= AllCards; card++ {
+			sumLeast, sumMost := 0, 0
+			for player := range b.least {
+				sumLeast += int(b.least[player][card])
+				sumMost += int(b.most[player][card])
+			}
+			for player := range b.least {
+				least, most := narrow(2-int(ht.PlayedCards[card]), sumLeast, sumMost, b.least[player][card], b.most[player][card])
+				if least != b.least[player][card] || most != b.most[player][card] {
+					b.least[player][card], b.most[player][card] = least, most
+					changed = true
+				}
+			}
+		}
+		for player := range b.least {
+			sumLeast, sumMost := 0, 0
+			for card := AS; int8(card) <= AllCards; card++ {
+				sumLeast += int(b.least[player][card])
+				sumMost += int(b.most[player][card])
+			}
+			for card := AS; int8(card) <= AllCards; card++ {
+				least, most := narrow(int(b.size[player]), sumLeast, sumMost, b.least[player][card], b.most[player][card])
+				if least != b.least[player][card] || most != b.most[player][card] {
+					b.least[player][card], b.most[player][card] = least, most
+					changed = true
+				}
+			}
+		}
+	}
+	return
+}
+
+// narrow returns the bounds on one of some parts that add up to total, it can't be more than the other parts leave
+// or less than they can't make up.  Bounds that would cross are left as they are for the sampler to find.
+func narrow(total, sumLeast, sumMost int, least, most uint8) (uint8, uint8) {
+	l, m := int(least), int(most)
+	if room := total - (sumLeast - l); room < m {
+		m = room
+	}
+	if need := total - (sumMost - int(most)); need > l {
+		l = need
+	}
+	if l > m {
+		return least, most
+	}
+	return uint8(l), uint8(m)
+}
+
+// DefaultPlays is how many plays an AI tries in its search for a card to play unless its Strategy says otherwise,
+// a count rather than a time so the same seed makes the same plays on any machine
+const DefaultPlays = 2000000
+
+// Strategy holds the knobs that make one AI play differently from another, the zero value plays like the original AI
+type Strategy struct {
+	BidOffset    int8          // added to every bid the AI calculates, in standard counters scaled with the Rules' Counters
+	ThrowinBelow uint8         // throw in after taking the bid when the hand was worth less than this many standard counters, 0 means 15
+	Plays        uint          // how many plays to try searching for a card to play, 0 means DefaultPlays
+	Samples      int           // deals of the unseen cards searched in parallel for each card to play, sharing the Plays, 0 means one per card in the hand
+	ThinkTime    time.Duration // also stops the search after this long when set, which makes the plays depend on the machine
+}
+
+type AI struct {
+	RealHand   *Hand
+	Trump      Suit
+	BidAmount  uint16
+	MaxBid     uint16 // what the hand is worth, worked out the first time it is asked to bid
+	HasBid     bool   // has been asked to bid this hand
+	HighBid    uint16
+	HighBidder uint8
+	NumBidders uint8
+	PlayerImpl
+	HT *HandTracker
+	Strategy
+	Source   *Source        // reseeded from the game every deal
+	Play     *PlayRules     // set every deal
+	Counting *CounterValues // set every deal
+}
+
+func (ai *AI) MarshalJSON() ([]byte, error) {
+	return json.Marshal("AI")
+}
+
+func (a *AI) reset() {
+	var err error
+	if a.HT == nil {
+		a.HT, err = getHT(a.Playerid)
+		if err != nil {
+			panic("not going to run out of memory here right?!")
+		}
+	}
+	a.HT.reset(a.Playerid)
+}
+
+func createAI() (a *AI) {
+	a = new(AI)
+	a.reset()
+	return a
+}
+
+// an AI outside of a game, like in the tests, draws from a fixed seed so its decisions repeat
+const aiSeed = 2
+
+func (ai *AI) random() *rand.Rand {
+	if ai.Source == nil {
+		ai.Source = NewSource(aiSeed)
+	}
+	return rand.New(ai.Source)
+}
+
+func (ai *AI) reseed(seed int64) {
+	ai.Source = NewSource(seed)
+}
+
+// computer is the AI sitting in a seat, also for the Players built on one like the ISMCTS
+func (ai *AI) computer() *AI {
+	return ai
+}
+
+// NewAI creates an AI that plays using strategy
+func NewAI(strategy Strategy) *AI {
+	a := createAI()
+	a.Strategy = strategy
+	return a
+}
+
+func (ai *AI) playRules() *PlayRules {
+	if ai.Play == nil { // never dealt in, like in the tests
+		return &StandardRules.Play
+	}
+	return ai.Play
+}
+
+func (ai *AI) counting() *CounterValues {
+	if ai.Counting == nil { // never dealt in, like in the tests
+		return StandardRules.Counting()
+	}
+	return ai.Counting
+}
+
+// playValue orders cards for playWithoutSearch, trump above every other suit then by face
+func playValue(card Card, trump Suit) int {
+	value := int(Nine - card.Face())
+	if card.Suit() == trump {
+		value += int(Nine)
+	}
+	return value
+}
+
+// playWithoutSearch takes the trick with the cheapest card that can, or throws off the cheapest legal card.
+// Leading it cashes an ace outside of trump when it has one.
+func (ai *AI) playWithoutSearch(action *Action) Card {
+	best := NACard
+	bestWins := false
+	for _, card := range *ai.RealHand {
+		if !ValidPlayWith(card, action.WinningCard, action.Lead, ai.RealHand, action.Trump, ai.playRules()) {
+			continue
+		}
+		if action.WinningCard == NACard && card.Face() == Ace && card.Suit() != action.Trump {
+			return card
+		}
+		wins := action.WinningCard == NACard || card.Beats(action.WinningCard, action.Trump)
+		if best == NACard || (wins && !bestWins) || (wins == bestWins && playValue(card, action.Trump) < playValue(best, action.Trump)) {
+			best, bestWins = card, wins
+		}
+	}
+	return best
+}
+
+// passCards picks count cards to pass, giving up as little of its own meld as it can.
+// The partner sends the bidder trump and aces, the bidder sends back the cards its meld and trump don't need.
+func (ai *AI) passCards(trump Suit, count uint8, toBidder bool, values *MeldValues) Hand {
+	hand := append(Hand(nil), *ai.RealHand...)
+	pass := make(Hand, 0, count)
+	for len(pass) < int(count) && len(hand) > 0 {
+		meld, _ := hand.MeldWith(trump, values)
+		best, bestScore := 0, 0
+		for x, card := range hand {
+			rest := append(append(Hand(nil), hand[:x]...), hand[x+1:]...)
+			without, _ := rest.MeldWith(trump, values)
+			strength := 0
+			if card.Suit() == trump {
+				strength += 6
+			}
+			if card.Face() == Ace {
+				strength += 4
+			} else if ai.counting().Value(card) > 0 {
+				strength++
+			}
+			score := (int(without) - int(meld)) * 2
+			if toBidder {
+				score += strength
+			} else {
+				score -= strength
+			}
+			if x == 0 || score > bestScore {
+				best, bestScore = x, score
+			}
+		}
+		pass = append(pass, hand[best])
+		hand = append(hand[:best], hand[best+1:]...)
+	}
+	return pass
+}
+
+// concedes is whether the bidder should throw in after seeing the meld, when the team's meld and the
+// counters it expects to take fall well short of the bid
+func (ai *AI) concedes(game *Game) bool {
+	team := ai.Playerid % game.teams()
+	scale := int(ai.counting().Scale())
+	return int(game.Meld[team])+ai.counterEstimate(game.Trump)+concedeMargin*scale < int(game.HighBid)
+}
+
+// counterEstimate is how much the AI expects its team to take in counters with trump, scored by its CounterValues.  Its aces,
+// trump tens and kings and long trump each take a trick, each trick is worth its share of everything the hand scores,
+// and its partners are given their share of whatever is left.
+func (ai *AI) counterEstimate(trump Suit) int {
+	seating := FourHanded
+	values := ai.counting()
+	deck := seating.Deck()
+	total := int(values.LastTrick)
+	for _, card := range deck {
+		total += int(values.Value(card))
+	}
+	tricks, trumps := 0, 0
+	for _, card := range *ai.RealHand {
+		if card.Suit() == trump {
+			trumps++
+		}
+		if card.Face() == Ace || (card.Suit() == trump && (card.Face() == Ten || card.Face() == King)) {
+			tricks++
+		}
+	}
+	if long := trumps - len(*ai.RealHand)/3; long > 0 {
+		tricks += long
+	}
+	mine := tricks * total / (len(deck) / int(seating.Players))
+	if mine > total {
+		mine = total
+	}
+	partners := int(seating.Players/seating.Teams) - 1
+	return mine + (total-mine)*partners/(int(seating.Players)-1)
+}
+
+// moonSamples is how many ways the AI deals out the cards it can't see before shooting the moon, it only shoots when it sweeps every one
+const moonSamples = 50
+
+// sweeps is whether the AI is all but sure to take every trick with trump
+func (ai *AI) sweeps(trump Suit) bool {
+	r := ai.random()
+	for x := 0; x < moonSamples; x++ {
+		hands, err := ai.HT.Deal(r)
+		if err != nil || !sweep(hands, ai.Playerid, trump) {
+			return false
+		}
+	}
+	return true
+}
+
+// sweep plays out hands with the bidder leading trump while the opponents have any and then whatever they can't beat.
+// The opponents throw off their lowest card whenever they can't take the trick, it returns false as soon as one could.
+func sweep(hands [4]*SmallHand, bidder uint8, trump Suit) bool {
+	var cards [4]Hand
+	for x := range hands {
+		for card := AS; int8(card) <= AllCards; card++ {
+			for y := int8(0); y < hands[x].Count(card); y++ {
+				cards[x] = append(cards[x], card)
+			}
+		}
+	}
+	opponents := []uint8{(bidder + 1) % 4, (bidder + 3) % 4}
+	beats := func(lead Card, hand Hand) bool {
+		follows := hand.CountSuit(lead.Suit()) > 0
+		for _, card := range hand {
+			if (follows && card.Suit() == lead.Suit() || !follows && card.Suit() == trump) && card.Beats(lead, trump) {
+				return true
+			}
+		}
+		return false
+	}
+	for len(cards[bidder]) > 0 {
+		trumpOut := true
+		for _, opponent := range opponents {
+			trumpOut = trumpOut && cards[opponent].CountSuit(trump) == 0
+		}
+		lead := NACard
+		for pass := 0; pass < 2 && lead == NACard; pass++ {
+			for _, card := range cards[bidder] {
+				if pass == 0 && (trumpOut || card.Suit() != trump) {
+					continue // draw trump first
+				}
+				if !beats(card, cards[opponents[0]]) && !beats(card, cards[opponents[1]]) {
+					lead = card
+					break
+				}
+			}
+		}
+		if lead == NACard {
+			return false
+		}
+		cards[bidder].Remove(lead)
+		for _, opponent := range opponents {
+			throw := NACard
+			follows := cards[opponent].CountSuit(lead.Suit()) > 0
+			for _, card := range cards[opponent] {
+				if (!follows || card.Suit() == lead.Suit()) && (throw == NACard || card.Face() > throw.Face()) {
+					throw = card
+				}
+			}
+			cards[opponent].Remove(throw)
+		}
+	}
+	return true
+}
+
+// concedeMargin is how far short the AI has to think it is before conceding, it can get lucky playing it out
+const concedeMargin = 5
+
+// powerBid is what the hand's cards add to its meld with suit as trump, weak suits count against it down to 0
+func (ai AI) powerBid(suit Suit) uint8 {
+	count := 5 // your partner's good for at least this right?!?
+	suitMap := make(map[Suit]int)
+	for _, card := range *ai.RealHand {
+		suitMap[card.Suit()]++
+		if card.Suit() == suit {
+			switch card.Face() {
+			case Ace:
+				count += 3
+			case Ten:
+				count += 2
+			case King:
+				fallthrough
+			case Queen:
+				fallthrough
+			case Jack:
+				fallthrough
+			case Nine:
+				count += 1
+			}
+		} else if card.Face() == Ace {
+			count += 2
+		} else if card.Face() == Jack || card.Face() == Nine {
+			count -= 1
+		}
+	}
+	for _, x := range Suits {
+		if x == suit {
+			continue
+		}
+		if suitMap[x] == 0 {
+			count++
+		}
+	}
+	if count < 0 {
+		return 0
+	}
+	return uint8(count)
+}
+
+func (ai AI) calculateBid(rules *Rules) (amount uint16, trump Suit, show Hand) {
+	r := ai.random()
+	scale := int(rules.Counting().Scale())
+	bids := make(map[Suit]int)
+	for _, suit := range Suits {
+		var meld uint16
+		meld, show = ai.RealHand.MeldWith(suit, &rules.Meld)
+		bids[suit] = int(meld) + int(ai.powerBid(suit))*scale
+		//		Log("Could bid %d in %s", bids[suit], suit)
+		if bids[trump] < bids[suit] {
+			trump = suit
+		} else if bids[trump] == bids[suit] {
+			//rand.Seed(time.Now().UnixNano())
+			if r.Intn(2) == 0 { // returns one in the set of [0,2)
+				trump = suit
+			} // else - stay with trump as it was
+		}
+	}
+	//rand.Seed(time.Now().UnixNano())
+	bid := bids[trump] + (r.Intn(3)+int(ai.BidOffset))*scale // adds 0, 1, or 2 for a little spontanaeity
+	switch {
+	case bid < 0:
+		return 0, trump, show
+	case bid > math.MaxUint16:
+		return math.MaxUint16, trump, show
+	}
+	return uint16(bid), trump, show
+}
+
+func max(a, b uint8) uint8 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type Trick struct {
+	Played        [8]Card // indexed by playerid, only the first size() are used
+	WinningPlayer uint8
+	Lead          uint8
+	Plays         uint8
+	Next          uint8 // the next player that needs to play
+	Players       uint8 // 0 means 4
+}
+
+func (t *Trick) size() uint8 {
+	if t.Players == 0 {
+		return 4
+	}
+	return t.Players
+}
+
+func (t *Trick) PlayCard(card Card, trump Suit) {
+	if t.Plays == t.size() {
+		t.reset()
+	}
+	t.Played[t.Next] = card
+	if t.Plays == 0 {
+		t.Lead = t.Next
+		t.WinningPlayer = t.Next
+	} else if card.Beats(t.Played[t.WinningPlayer], trump) {
+		t.WinningPlayer = t.Next
+	}
+	t.Plays++
+	t.Next = (t.Next + 1) % t.size()
+	if t.Plays == t.size() {
+		t.Next = t.WinningPlayer
+	}
+	//Log(4, "After trick.PlayCard - %s", t)
+	//Log(4, "After trick.PlayCard - %#v", t)
+}
+
+func (t *Trick) reset() {
+	t.Plays = 0
+}
+
+// Worth is how the hand has gone for the team that made the last play, three times the counters it took less the trump, aces
+// and tens it spent taking them.  Those are worth scale counters, the CounterValues' Scale.
+func (pw *PlayWalker) Worth(trump Suit, scale int16) (worth int16) {
+	worth = int16(pw.Counters[pw.Me%2]) * 3
+	for card := AS; int8(card) <= AllCards; card++ {
+		cost := spendCost(card, trump, scale)
+		worth -= int16(pw.TeamCards[pw.Me%2].Count(card)) * cost     // teammate
+		worth += int16(pw.TeamCards[(pw.Me+1)%2].Count(card)) * cost // opponent
+	}
+	return
+}
+
+// spendCost is what Worth takes off for playing the card, scale for trump and tens and twice that for aces.
+func spendCost(card Card, trump Suit, scale int16) (cost int16) {
+	if card.Suit() == trump {
+		cost += scale
+	}
+	if card.Face() == Ace {
+		cost += scale * 2
+	} else if card.Face() == Ten {
+		cost += scale
+	}
+	return
+}
+
+func (t *Trick) String() string {
+	if t == nil {
+		return ""
+	}
+	var str bytes.Buffer
+	str.WriteString("-")
+	if t.Plays == 0 {
+		return "-----"
+	}
+	var printme [8]bool
+	walker := t.Lead + t.size() - 1
+	for x := uint8(0); x < t.Plays; x++ {
+		walker = (walker + 1) % t.size()
+		printme[walker] = true
+	}
+	for y := uint8(0); y < t.size(); y++ {
+		if printme[y] {
+			if t.Lead == y {
+				str.WriteString("l")
+			}
+			if t.WinningPlayer == y {
+				str.WriteString("w")
+			}
+			str.WriteString(fmt.Sprintf("%s-", t.Played[y]))
+		} else {
+			str.WriteString("-")
+		}
+	}
+	return str.String()
+}
+
+func (trick *Trick) leadSuit() Suit {
+	if trick.Plays == 0 {
+		return NASuit
+	}
+	return trick.Played[trick.Lead].Suit()
+}
+
+func (trick *Trick) winningCard() Card {
+	if trick.Plays == 0 {
+		return NACard
+	}
+	return trick.Played[trick.WinningPlayer]
+}
+
+func (trick *Trick) counters(values *CounterValues) (counters uint16) {
+	if trick.Plays != trick.size() {
+		panic("can't get counters before the trick is finished")
+	}
+	for _, card := range trick.Played[:trick.size()] {
+		counters += values.Value(card)
+	}
+	return
+}
+
+func CardBeatsTrick(card Card, trick *Trick, trump Suit) bool {
+	return card.Beats(trick.winningCard(), trump)
+}
+
+type PlayWalker struct {
+	Parent    *PlayWalker
+	Children  []*PlayWalker
+	Card      Card
+	Hands     [4]*SmallHand
+	TeamCards [2]*SmallHand
+	Counters  [2]uint16
+	Trick     *Trick
+	PlayCount uint8
+	Me        uint8
+	//Best      *PlayWalker // used for debugging
+	//Count     uint // used for debugging
+}
+
+func (walker *PlayWalker) PlayTrail() string {
+	if walker == nil {
+		return ""
+	}
+	var str bytes.Buffer
+	//str.WriteString(strconv.Itoa(int(walker.Count)))
+	tricks := make([]*Trick, 0)
+	tricks = append([]*Trick{walker.Trick}, tricks...)
+	for {
+		if walker.Parent == nil {
+			break
+		}
+		walker = walker.Parent
+		if walker.Trick != nil && walker.Trick.Plays == 4 {
+			tricks = append([]*Trick{walker.Trick}, tricks...)
+		}
+	}
+	for x := range tricks {
+		str.WriteString(tricks[x].String())
+		str.WriteString(" ")
+	}
+	return str.String()
+}
+
+// playHandWithCard searches samples deals of the cards the owner can't see, spread across runtime.NumCPU() workers,
+// and returns the card that does best over all of them with the number of plays searched, or ErrImpossibleDeal.
+// Each deal gets an even share of plays and every search stops once think has passed when it's set, samples of 0 deals one per card in the owner's hand.
+func playHandWithCard(ht *HandTracker, trump Suit, samples int, plays uint, think time.Duration, r *rand.Rand) (Card, uint, error) {
+	rules := ht.playRules()
+	counting := ht.counting()
+	if samples <= 0 {
+		// TODO: update samples to be the count of "unknown" cards in the HandTracker
+		samples = int(ht.calculateHand(ht.Owner))
+	}
+	sampler, err := ht.sampler()
+	if err != nil {
+		return NACard, 0, err
+	}
+	roots := make([]*PlayWalker, samples)
+	for x := range roots { // dealt up front, neither the sampler nor r can be shared by the workers
+		roots[x] = &PlayWalker{
+			Hands:     sampler.deal(r),
+			Card:      NACard,
+			Trick:     new(Trick),
+			PlayCount: ht.PlayCount,
+			Me:        ht.Owner,
+			TeamCards: [2]*SmallHand{NewSmallHand(), NewSmallHand()},
+		}
+		*roots[x].Trick = *ht.Trick
+	}
+	// the owner's hand is the same in every deal, so every root has the same potential cards in the same order
+	decisionMap := roots[0].potentialCards(roots[0].Trick, trump, rules, counting)
+	if len(decisionMap) == 1 {
+		// no need to search, this was the only legal play
+		Log(ht.Owner, "Returning the only legal play of %s", decisionMap[0])
+		return decisionMap[0], 0, nil
+	}
+	var end time.Time
+	if think > 0 {
+		end = time.Now().Add(think)
+	}
+	share := plays / uint(samples)
+	aggregateScore := make([]int, len(decisionMap))
+	count := uint(0)
+	var lock sync.Mutex
+	deals := make(chan *PlayWalker)
+	var wg sync.WaitGroup
+	for x := 0; x < runtime.NumCPU() && x < samples; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			searcher := newSearcher(ht.Owner, trump, rules, counting, end)
+			for root := range deals {
+				scores, searched := searcher.search(root, decisionMap, share)
+				lock.Lock()
+				for c := range scores {
+					aggregateScore[c] += scores[c]
+				}
+				count += searched
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, root := range roots {
+		deals <- root
+	}
+	close(deals)
+	wg.Wait()
+	bestChild := 0
+	for c := range aggregateScore {
+		if aggregateScore[c] > aggregateScore[bestChild] {
+			bestChild = c
+		}
+	}
+	Log(ht.Owner, "Returning best play #%d %s with worth %d for the following path(s):", bestChild, decisionMap[bestChild], aggregateScore[bestChild])
+	return decisionMap[bestChild], count, nil
+}
+
+func (ai *AI) findCardToPlay(action *Action) (Card, uint) {
+	ai.HT.Trick.Next = action.Playerid
+	plays := ai.Plays
+	if plays == 0 {
+		plays = DefaultPlays
+	}
+	card, amount, err := playHandWithCard(ai.HT, action.Trump, ai.Samples, plays, ai.ThinkTime, ai.random())
+	if err != nil { // the HandTracker has lost track, play like the AI does without it
+		Log(ai.Playerid, "Playing without searching - %v", err)
+		return ai.playWithoutSearch(action), 0
+	}
+	runtime.GC() // since we created so much garbage, we need to have the GC mark it as unlinked/unused so next round it can be reused
+	//Log(ai.Playerid, "PlayHandWithCard returned %s for %d points.", card, points)
+	return card, amount
+}
+
+// potentialCards are the plays worth searching from the next player's hand that rules allow on trick
+func (pw *PlayWalker) potentialCards(trick *Trick, trump Suit, rules *PlayRules, values *CounterValues) Hand {
+	return potentialPlays(pw.Hands[trick.Next], trick, trump, rules, values)
+}
+
+// potentialPlays are the plays worth searching from hand that rules allow on trick, potentialCards without a PlayWalker.
+// Cards that do the same thing to the trick and score the same by values are searched as one.
+func potentialPlays(hand *SmallHand, trick *Trick, trump Suit, rules *PlayRules, values *CounterValues) Hand {
+	//Log(ht.Owner, "PotentialCards called with %d,winning=%s,lead=%s,trump=%s", playerid, winning, lead, trump)
+	//Log(ht.Owner, "PotentialCards Player%d - %s", playerid, ht.Cards[playerid])
+	validHand := getHand()
+	winning := NACard
+	lead := NASuit
+	if trick.Plays != 4 {
+		winning = trick.winningCard()
+		lead = trick.leadSuit()
+	}
+	var statuses [AllCards + 1]int
+allCardLoop:
+	for _, card := range LegalPlays(hand, winning, lead, trump, rules) {
+		cardStatus := PlayStatus(card, winning, lead, trump)
+		statuses[card] = cardStatus
+		if (cardStatus == FollowLose || cardStatus == TrumpLose) ||
+			((cardStatus == FollowWin || cardStatus == TrumpWin) && trick.Plays == 3) {
+			// there should be a maximum of one card for each status and value in validHand
+			//Log(ht.Owner, "ValidHand=%s", validHand)
+			for y, vhc := range validHand {
+				//Log(4, "Comparing vhc=%s to card=%s", vhc, card)
+				if statuses[vhc] == cardStatus && values.Value(vhc) == values.Value(card) {
+					if card > vhc {
+						//Log(4, "Replacing %s with %s", vhc, card)
+						validHand[y] = card
+					}
+					continue allCardLoop
+				}
+			}
+		}
+		//Log(4, "Appending card valid normal")
+		validHand = append(validHand, card)
+	}
+	//sLog(4, "Returning %d potential plays of %s for playerid %d on trick %s", len(validHand), validHand, pw.Trick.Next, pw.Trick)
+	//if len(validHand) == 0 && ht.PlayCount != 48 {
+	//	ht.Debug()
+	//	panic("hand is not at the end but still returning 0 potential cards")
+	//}
+	return validHand
+}
+
+func (ai *AI) Tell(env *Env, game *Game, action *Action) *Action {
+	//Log(ai.Playerid, "Action received - %+v", action)
+	switch action.Type {
+	case "Bid":
+		if action.Playerid == ai.Playerid {
+			//Log(ai.Playerid, "------------------Player %d asked to bid against player %d", ai.Playerid, ai.HighBidder)
+			rules := game.rules()
+			next := ai.HighBid + rules.Raise()
+			if ai.HasBid { // back around the auction, keep raising the opponents by the minimum while the hand is worth it
+				ai.BidAmount = 0
+				if !ai.IsPartner(ai.HighBidder) && next <= ai.MaxBid {
+					ai.BidAmount = next
+				}
+				return CreateBid(ai.BidAmount, ai.Playerid)
+			}
+			ai.HasBid = true
+			ai.MaxBid, ai.Trump, _ = ai.calculateBid(rules)
+			if ai.NumBidders == 1 && ai.IsPartner(ai.HighBidder) && ai.MaxBid <= rules.MinimumBid && ai.MaxBid+5*rules.Counting().Scale() > rules.MinimumBid {
+				// save our parter
+				//Log(ai.Playerid, "Saving our partner with a recommended bid of %d", ai.MaxBid)
+				ai.MaxBid = rules.MinimumBid + rules.Raise()
+			}
+			ai.BidAmount = ai.MaxBid
+			switch {
+			case ai.HighBid > ai.BidAmount:
+				ai.BidAmount = 0
+			case ai.HighBid == ai.BidAmount && !ai.IsPartner(ai.HighBidder): // if equal with an opponent, bid one over them for spite!
+				ai.BidAmount = next
+				ai.MaxBid = next
+			case ai.NumBidders == FourHanded.Players-1: // I'm last to bid the first time around, but I want it
+				ai.BidAmount = next
+			}
+			if ai.BidAmount < next {
+				ai.BidAmount = 0
+			}
+			//meld, _ := ai.RealHand.Meld(ai.Trump)
+			//Log(ai.Playerid, "------------------Player %d bid %d over %d with recommendation of %d and %d meld", ai.Playerid, ai.BidAmount, ai.HighBid, bidAmountOld, meld)
+			return CreateBid(ai.BidAmount, ai.Playerid)
+		} else {
+			// received someone else's bid value'
+			if ai.HighBid < action.Bid {
+				ai.HighBid = action.Bid
+				ai.HighBidder = action.Playerid
+			}
+			ai.NumBidders++
+		}
+	case "Play":
+		fallthrough
+	case "PlayRequest":
+		//Log(ai.Playerid, "Trick = %s", ai.Trick)
+		var response *Action
+		if action.Playerid == ai.Playerid && game != nil && game.State == StateMeld && ai.concedes(game) {
+			return CreateThrowin(ai.Playerid)
+		}
+		if action.Playerid == ai.Playerid {
+			var start = time.Now()
+			card, amount := ai.findCardToPlay(action)
+			response = CreatePlay(card, ai.Playerid)
+			if env != nil {
+				env.Debugf("Logged %d unique paths in %s", amount, time.Now().Sub(start))
+			}
+			action.PlayedCard = response.PlayedCard
+		}
+		ai.HT.Trick.Next = action.Playerid
+		ai.HT.PlayCard(action.PlayedCard, ai.Trump)
+		//Log(ai.Playerid, "Player %d played card %s on %s", action.Playerid, action.PlayedCard, ai.HT.Trick)
+		return response
+	case "RedealRequest":
+		if action.Playerid == ai.Playerid {
+			rules := game.rules()
+			if bid, _, _ := ai.calculateBid(rules); bid < rules.MinimumBid { // wouldn't open, hope for better cards
+				return CreateRedeal(ai.Playerid)
+			}
+			return CreateKeep(ai.Playerid)
+		}
+	case "MeldRequest":
+		if action.Playerid == ai.Playerid { // show everything, the AI doesn't make mistakes
+			meld, shown := ai.Hand().MeldWith(action.Trump, &game.rules().Meld)
+			return CreateMeld(shown, meld, ai.Playerid)
+		}
+	case "Trump":
+		if action.Playerid == ai.Playerid {
+			if !ai.HasBid { // the dealer was stuck without being asked to bid
+				ai.HasBid = true
+				ai.MaxBid, ai.Trump, _ = ai.calculateBid(game.rules())
+				ai.BidAmount = ai.MaxBid
+			}
+			//meld, _ := ai.RealHand.Meld(ai.Trump)
+			//Log(ai.Playerid, "Player %d being asked to name trump on hand %s and have %d meld", ai.Playerid, ai.RealHand, meld)
+			throwinBelow := uint16(ai.ThrowinBelow)
+			if throwinBelow == 0 {
+				throwinBelow = 15
+			}
+			switch {
+			// TODO add case for the end of the game like if opponents will coast out
+			case ai.BidAmount < throwinBelow*ai.counting().Scale():
+				return CreateThrowin(ai.Playerid)
+			case game.rules().MoonBonus > 0 && ai.sweeps(ai.Trump):
+				return CreateMoon(ai.Trump, ai.Playerid)
+			default:
+				return CreateTrump(ai.Trump, ai.Playerid)
+			}
+		} else {
+			ai.Trump = action.Trump
+			//Log(ai.Playerid, "Trump is %s", ai.Trump)
+		}
+	case "Moon":
+		ai.Trump = action.Trump
+	case "Throwin":
+		//Log(ai.Playerid, "Player %d saw that player %d threw in", ai.Playerid, action.Playerid)
+	case "Pass":
+		if action.Playerid == ai.Playerid {
+			rules := game.rules()
+			to := game.HighPlayer
+			if ai.Playerid == game.HighPlayer {
+				to = game.partner()
+			}
+			pass := ai.passCards(ai.Trump, rules.PassCards, to == game.HighPlayer, &rules.Meld)
+			ai.HT.pass(pass, ai.Playerid, to)
+			return CreatePass(pass, ai.Playerid)
+		}
+		ai.HT.pass(action.Hand, action.Playerid, ai.Playerid)
+	case "Deal":
+		ai.reset()
+		ai.RealHand = &action.Hand
+		//Log(ai.Playerid, "Set playerid")
+		//Log(ai.Playerid, "Dealt Hand = %s", ai.RealHand.String())
+		ai.populate()
+		ai.HighBid = game.rules().MinimumBid
+		ai.HighBidder = action.Dealer
+		ai.NumBidders = 0
+		ai.HasBid = false
+	case "Meld":
+		//Log(ai.Playerid, "Received meld action - %#v", action)
+		if action.Playerid == ai.Playerid {
+			return nil // seeing our own meld, we don't care
+		}
+		for cardIndex, shown := range action.Hand.Count() {
+			if shown == 0 {
+				continue
+			}
+			// what was passed to them is already known, only raise it
+			if val := ai.HT.Cards[action.Playerid][cardIndex]; val == Unknown || val < shown {
+				ai.HT.Cards[action.Playerid][cardIndex] = shown
+			}
+			ai.HT.calculateCard(cardIndex)
+		}
+		ai.HT.calculateHand(ai.Playerid)
+	case "Message": // nothing to do here, no one to read it
+	case "Trick": // nothing to do here, nothing to display
+		//Log(ai.Playerid, "playedCards=%v", ai.HT.PlayedCards)
+		ai.HT.Trick.reset()
+	case "Score": // TODO: save score to use for future bidding techniques
+	default:
+		//Log(ai.Playerid, "Received an action I didn't understand - %v", action)
+	}
+	return nil
+}
+
+func (a *AI) Hand() *Hand {
+	return a.RealHand
+}
+
+func (a *AI) SetHand(env *Env, game *Game, h Hand, dealer, playerid uint8) {
+	a.Playerid = playerid
+	a.Play = &game.rules().Play
+	a.Counting = game.rules().Counting()
+	a.Teams = game.seating().Teams
+	hand := make(Hand, len(h))
+	copy(hand, h)
+	a.Tell(env, game, CreateDeal(hand, playerid, dealer))
+}
+
+type Human struct {
+	RealHand *Hand
+	Client   *Client
+	PlayerImpl
+}
+
+func (h *Human) MarshalJSON() ([]byte, error) {
+	log.Printf("Logging from MarshalJSON in Human on %s\n", h.Client.Name)
+	return json.Marshal(h.Client.Name)
+}
+
+func (h *Human) Tell(env *Env, game *Game, action *Action) *Action {
+	return h.Client.Tell(env, game, action)
+}
+
+func (h Human) Hand() *Hand {
+	return h.RealHand
+}
+
+func (a *Human) SetHand(env *Env, game *Game, h Hand, dealer, playerid uint8) {
+	hand := make(Hand, len(h))
+	copy(hand, h)
+	a.RealHand = &hand
+	a.Playerid = playerid
+	a.Teams = game.seating().Teams
+	a.Tell(env, game, CreateDeal(hand, a.Playerid, dealer))
+}
+
+// reject logs why an action was refused and tells the client that sent it in an Error action,
+// or the player it claims to be from when it didn't come from a client
+func (game *Game) reject(env *Env, client *Client, playerid uint8, err error) {
+	logError(env, err)
+	if client != nil {
+		client.Tell(env, game, CreateError(err))
+	} else if int(playerid) < len(game.Players) && game.Players[playerid] != nil {
+		game.Players[playerid].Tell(env, game, CreateError(err))
+	}
+}
+
+// unexpected is why an action the game isn't waiting on is refused
+func (game *Game) unexpected(action *Action) error {
+	if action.Playerid != game.Next {
+		return ErrNotYourTurn
+	}
+	return ErrUnexpectedAction
+}
+
+func logError(env *Env, err error) bool {
+	if err != nil {
+		if env != nil {
+			env.Errorf("Error - %v", err)
+			//debug.PrintStack()
+			env.Debugf("Stack = %s", debug.Stack())
+		}
+		return true
+	}
+	return false
+}
+
+type Game struct {
+	Id          int64
+	Trick       Trick `json:"-"`
+	Players     []Player
+	Dealer      uint8 `json:"-"`
+	Score       []int16
+	Meld        []uint16
+	CountMeld   []bool   `json:"-"`
+	Counters    []uint16 `json:"-"`
+	HighBid     uint16
+	HighPlayer  uint8
+	Trump       Suit
+	State       string
+	Next        uint8
+	Hands       []Hand    `json:"-"`
+	Widow       Hand      `json:"-"` // picked up by the bidder, then the cards it buried which count for it
+	Passed      []bool    `json:"-"` // who has dropped out of the auction
+	HandsPlayed uint8     `json:"-"`
+	Updated     time.Time `json:"-"`
+	Source      *Source   `json:"-"` // every shuffle and AI decision in the game draws from here
+	Record      *Record   `json:"-"`
+	Rules       Rules     `json:"-"`
+	Moon        bool      `json:"-"` // the bidder shot the moon, the hand ends as soon as the bidders lose a trick
+}
+
+// NewGame creates a game of AI players played by rules, the same seed deals the same hands and feeds the AI the same random choices
+func NewGame(players int, seed int64, rules Rules) *Game {
+	game := new(Game)
+	game.Source = NewSource(seed)
+	game.Rules = rules
+	game.Record = &Record{Seed: seed, Rules: rules}
+	game.Players = make([]Player, players)
+	for x := range game.Players {
+		game.Players[x] = createAI()
+	}
+	game.Score = make([]int16, game.seating().Teams)
+	game.Meld = make([]uint16, game.seating().Teams)
+	game.State = StateNew
+	return game
+}
+
+// PRE : Players are already created and set
+func (game *Game) NextHand(env *Env) (*Game, error) {
+	seating, err := NewSeating(uint8(len(game.Players)), game.rules().DoubleDeck)
+	if err != nil {
+		return game, err
+	}
+	if seating != FourHanded {
+		for _, player := range game.Players {
+			if _, ok := player.(interface{ computer() *AI }); ok {
+				return game, ErrComputerSeating
+			}
+		}
+	}
+	game.Meld = make([]uint16, seating.Teams)
+	game.Trick = Trick{Players: seating.Players}
+	game.CountMeld = make([]bool, seating.Teams)
+	game.Counters = make([]uint16, seating.Teams)
+	game.HighBid = game.rules().MinimumBid
+	game.HighPlayer = game.Dealer
+	game.Moon = false
+	game.Passed = make([]bool, seating.Players)
+	game.State = StateBid
+	game.Next = game.Dealer
+	//Log(4, "Dealer is %d", game.Dealer)
+	deck := seating.Deck()
+	r := game.random()
+	deck.Shuffle(r)
+	var hands []Hand
+	hands, game.Widow = deck.DealTo(int(seating.Players), int(seating.Widow))
+	if err := seating.CheckDeal(hands, game.Widow); err != nil {
+		game.recordDeal(hands)
+		game.recordMisdeal()
+		game.recordScore(env, false)
+		game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Misdeal, %v, player %d deals again", err, game.Dealer)))
+		return game.NextHand(env)
+	}
+	for x := uint8(0); x < uint8(len(game.Players)); x++ {
+		game.Next = game.inc()
+		sort.Sort(hands[x])
+		seed := r.Int63() // drawn for every seat so the deals don't depend on who is sitting where
+		if player, ok := game.Players[game.Next].(interface{ reseed(int64) }); ok {
+			player.reseed(seed)
+		}
+		game.Players[game.Next].SetHand(env, game, hands[x], game.Dealer, game.Next)
+		//Log(4, "Dealing player %d hand %s", game.Next, game.Players[game.Next].Hand())
+	}
+	game.recordDeal(hands)
+	return game.ProcessAction(env, nil, game.askRedeal(env, game.Dealer))
+	// processAction will write the game to the GameStore when it's done processing the action(s)
+}
+
+// askRedeal asks the next player after from, up to the dealer, whose hand can be thrown back if they want a redeal.
+// Once nobody is left to ask the bidding starts.
+func (game *Game) askRedeal(env *Env, from uint8) *Action {
+	rules := game.rules()
+	game.Next = from
+	if rules.CallRedeal && (game.State != StateRedeal || from != game.Dealer) {
+		for {
+			game.Next = game.inc()
+			if game.Players[game.Next].Hand().CanRedeal(&rules.Meld) {
+				game.State = StateRedeal
+				return game.Players[game.Next].Tell(env, game, CreateRedealRequest(game.Next))
+			}
+			if game.Next == game.Dealer {
+				break
+			}
+		}
+	}
+	game.State = StateBid
+	game.Next = game.Dealer
+	game.Next = game.inc() // increment so that Dealer + 1 is asked to bid first
+	return game.Players[game.Next].Tell(env, game, CreateBid(0, game.Next))
+}
+
+// rules are the game's Rules, StandardRules outside of a game or for games stored before they had Rules
+func (game *Game) rules() *Rules {
+	if game == nil || (game.Rules.GameTarget == 0 && game.Rules.Meld == MeldValues{}) {
+		return &StandardRules
+	}
+	return &game.Rules
+}
+
+// seating is how the game's players are dealt and partnered, NextHand won't deal a seating that NewSeating rejects
+func (game *Game) seating() Seating {
+	if game == nil {
+		return FourHanded
+	}
+	seating, err := NewSeating(uint8(len(game.Players)), game.rules().DoubleDeck)
+	if err != nil {
+		return FourHanded
+	}
+	return seating
+}
+
+func (game *Game) teams() uint8 {
+	return game.seating().Teams
+}
+
+// partner is who trades cards with the bidder in StatePass, the next player on the bidder's team
+func (game *Game) partner() uint8 {
+	return (game.HighPlayer + game.teams()) % uint8(len(game.Players))
+}
+
+// passes is whether the bidder and partner trade cards this game
+func (game *Game) passes() bool {
+	return game.rules().PassCards > 0 && game.teams() < uint8(len(game.Players))
+}
+
+// pass moves cards from one player's hand to another's, it returns an error without moving anything unless from holds every card
+func (game *Game) pass(cards Hand, from, to uint8) error {
+	if len(cards) != int(game.rules().PassCards) {
+		return ErrPassCount
+	}
+	hand := append(Hand(nil), *game.Players[from].Hand()...)
+	for _, card := range cards {
+		if !hand.Remove(card) {
+			return ErrCardNotInHand
+		}
+	}
+	*game.Players[from].Hand() = hand
+	received := game.Players[to].Hand()
+	*received = append(*received, cards...)
+	sort.Sort(*received)
+	return nil
+}
+
+// bury takes the cards the bidder buries out of its hand once it has picked up the widow, they take the widow's place and count for the bidder
+func (game *Game) bury(cards Hand) error {
+	if len(cards) != len(game.Widow) {
+		return ErrBuryCount
+	}
+	hand := append(Hand(nil), *game.Players[game.HighPlayer].Hand()...)
+	for _, card := range cards {
+		if !hand.Remove(card) {
+			return ErrCardNotInHand
+		}
+	}
+	*game.Players[game.HighPlayer].Hand() = hand
+	game.Widow = append(Hand(nil), cards...)
+	sort.Sort(game.Widow)
+	return nil
+}
+
+// startPlay scores everyone's meld and asks the bidder to lead, under Rules.DeclareMeld the bidder is asked to show their meld first
+func (game *Game) startPlay(env *Env) *Action {
+	if game.rules().DeclareMeld {
+		game.State = StateDeclare
+		game.Next = game.HighPlayer
+		return game.Players[game.Next].Tell(env, game, CreateMeldRequest(game.Trump, game.Next))
+	}
+	for x := uint8(0); x < uint8(len(game.Players)); x++ {
+		game.showMeld(env, x, game.Players[x].Hand().MeldItems(game.Trump, &game.rules().Meld))
+	}
+	return game.lead(env)
+}
+
+// showMeld tells everyone the meld playerid shows and scores it for their team
+func (game *Game) showMeld(env *Env, playerid uint8, items Melds) {
+	action := CreateMeldBreakdown(items, playerid)
+	game.BroadcastAll(env, action)
+	game.Meld[playerid%game.teams()] += action.Amount
+	game.recordMeld(playerid, action.Amount)
+}
+
+// declare checks the meld playerid declared, returning why the declaration is rejected
+func (game *Game) declare(env *Env, playerid uint8, declared Hand) error {
+	rules := game.rules()
+	items, mistakes, ok := game.Players[playerid].Hand().Declare(declared, game.Trump, &rules.Meld)
+	switch {
+	case !ok:
+		return ErrCardNotInHand
+	case len(mistakes) > 0 && rules.MeldPenalty == 0:
+		return fmt.Errorf("%w, %s", ErrNotMeld, mistakes)
+	}
+	game.recordDeclare(playerid, declared)
+	game.showMeld(env, playerid, items)
+	if len(mistakes) > 0 {
+		penalty := rules.MeldPenalty * uint16(len(mistakes))
+		game.Score[playerid%game.teams()] -= int16(penalty)
+		game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Player %d showed %s that isn't meld, Team%d loses %d", playerid, mistakes, playerid%game.teams(), penalty)))
+	}
+	return nil
+}
+
+// playRequest asks playerid to play to the trick, with the cards the rules let them play
+func (game *Game) playRequest(playerid uint8) *Action {
+	hand := game.Players[playerid].Hand()
+	action := CreatePlayRequest(game.Trick.winningCard(), game.Trick.leadSuit(), game.Trump, playerid, hand)
+	action.Legal = LegalPlays(hand, action.WinningCard, action.Lead, game.Trump, &game.rules().Play)
+	return action
+}
+
+// lead asks the bidder to lead once the meld is shown
+func (game *Game) lead(env *Env) *Action {
+	game.Next = game.HighPlayer
+	game.Counters = make([]uint16, game.teams())
+	for _, card := range game.Widow {
+		game.Counters[game.HighPlayer%game.teams()] += game.rules().Counting().Value(card)
+	}
+	game.State = StatePlay
+	if game.rules().ConcedeAfterMeld && !game.Moon { // the bidder leads or throws in
+		game.State = StateMeld
+	}
+	return game.Players[game.Next].Tell(env, game, game.playRequest(game.Next))
+}
+
+func (game *Game) nextDealer() {
+	game.Dealer = (game.Dealer + 1) % uint8(len(game.Players))
+}
+
+// scores describes every team's score for a message, like Team0 = 20 to Team1 = 34
+func (game *Game) scores() string {
+	var buf bytes.Buffer
+	for x, score := range game.Score {
+		if x > 0 {
+			buf.WriteString(" to ")
+		}
+		fmt.Fprintf(&buf, "Team%d = %d", x, score)
+	}
+	return buf.String()
+}
+
+func (game *Game) random() *rand.Rand {
+	if game.Source == nil { // stored before games had their own source
+		game.Source = NewSource(time.Now().UnixNano())
+	}
+	return rand.New(game.Source)
+}
+
+func (game *Game) inc() uint8 {
+	return (game.Next + 1) % uint8(len(game.Players))
+}
+
+func (game *Game) Broadcast(env *Env, a *Action, p uint8) {
+	for x, player := range game.Players {
+		if p != uint8(x) {
+			player.Tell(env, game, a)
+		}
+	}
+}
+
+func (game *Game) BroadcastAll(env *Env, a *Action) {
+	game.Broadcast(env, a, uint8(len(game.Players)))
+}
+
+func (game *Game) Retell(env *Env) {
+	game.RetellPlayer(env, game.Next)
+}
+
+// RetellPlayer replays what player p needs to pick the hand back up, asking for its action if the game is waiting on it
+func (game *Game) RetellPlayer(env *Env, p uint8) {
+	player := game.Players[p]
+	switch game.State {
+	case StateNew:
+		// do nothing, we're not waiting on anyone in particular
+	case StateBid:
+		player.Tell(env, game, CreateDeal(*player.Hand(), p, game.Dealer))
+		if p == game.Next {
+			player.Tell(env, game, CreateBid(game.HighBid, p))
+		}
+	case StateTrump:
+		player.Tell(env, game, CreateDeal(*player.Hand(), p, game.Dealer))
+		if p == game.Next {
+			player.Tell(env, game, CreateTrump(NASuit, p))
+		}
+	case StateBury:
+		player.Tell(env, game, CreateDeal(*player.Hand(), p, game.Dealer))
+		if p == game.Next {
+			player.Tell(env, game, CreateBury(nil, p))
+		}
+	case StatePass:
+		player.Tell(env, game, CreateDeal(*player.Hand(), p, game.Dealer))
+		if p == game.Next {
+			player.Tell(env, game, CreatePass(nil, p))
+		}
+	case StateDeclare:
+		player.Tell(env, game, CreateDeal(*player.Hand(), p, game.Dealer))
+		if p == game.Next {
+			player.Tell(env, game, CreateMeldRequest(game.Trump, p))
+		}
+	case StateMeld, StatePlay:
+		if p != game.Next {
+			player.Tell(env, game, CreateDeal(*player.Hand(), p, game.Dealer))
+		}
+		if game.Trick.Plays != 0 {
+			x := game.Trick.Lead
+			for y := uint8(0); y < game.Trick.Plays; y++ {
+				player.Tell(env, game, CreatePlay(game.Trick.Played[x], x))
+				x = (x + 1) % game.Trick.size()
+			}
+		}
+		if p == game.Next {
+			player.Tell(env, game, game.playRequest(p))
+		}
+	}
+}
+
+// finishHand announces the scores after a hand, then ends the game or deals the next hand
+func (game *Game) finishHand(env *Env) (*Game, error) {
+	teams := game.teams()
+	bidders := game.HighPlayer % teams
+	// check the score for a winner
+	game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Scores are now %s, played %d hands", game.scores(), game.HandsPlayed)))
+	//Log(4, "Scores are now Team0 = %d to Team1 = %d, played %d hands", game.Score[0], game.Score[1], game.HandsPlayed)
+	win := make([]bool, teams)
+	gameOver := false
+	if game.Score[bidders] >= game.rules().GameTarget { // the bidders go out first
+		win[bidders] = true
+		gameOver = true
+	} else {
+		winner := bidders
+		for team := uint8(0); team < teams; team++ {
+			if game.Score[team] >= game.rules().GameTarget && (winner == bidders || game.Score[team] > game.Score[winner]) {
+				winner = team
+			}
+		}
+		if winner != bidders {
+			win[winner] = true
+			gameOver = true
+		}
+	}
+	game.recordScore(env, gameOver)
+	for x := 0; x < len(game.Players); x++ {
+		game.Players[x].Tell(env, game, CreateScore(game.Score, gameOver, win[uint8(x)%teams]))
+	}
+	if gameOver {
+		for _, player := range game.Players {
+			if human, ok := player.(*Human); ok {
+				logError(env, env.Store.GetClient(human.Client))
+				human.Client.TableId = 0
+				logError(env, env.Store.PutClient(human.Client))
+			} else if player, ok := player.(interface{ computer() *AI }); ok {
+				ai := player.computer()
+				htstack.Push(ai.HT)
+				ai.HT = nil // it's another game's now
+			}
+		}
+		if game.Id != 0 {
+			logError(env, env.Store.DeleteGame(game))
+		}
+		return nil, nil // game over
+	}
+	game.nextDealer()
+	//Log(4, "-----------------------------------------------------------------------------")
+	return game.NextHand(env)
+}
+
+// client parameter only required for actions that modify the client, like sitting at a table, setting your name, etc
+// when it's set and the action is refused, the Error goes to the client rather than the seat the action names
+func (game *Game) ProcessAction(env *Env, client *Client, action *Action) (*Game, error) {
+	for sender := client; ; sender = nil { // only the first action is the client's, the rest are the players' answers
+		if game == nil {
+			env.Debugf("processAction on %s", action)
+		} else {
+			env.Debugf("processAction on %s with game.Id = %d", action, game.Id)
+		}
+		if action == nil {
+			// waiting on a human, save the state and exit
+			logError(env, env.Store.PutGame(game))
+			env.Debugf("ProcessAction returning %#v", game)
+			return game, nil
+		}
+		switch {
+		case action.Type == "Tables":
+			client.SendTables(env, game)
+			return game, nil
+		case action.Type == "Name":
+			client.Name = action.Message
+			err := env.Store.PutClient(client)
+			env.Debugf("Saving name change")
+			logError(env, err)
+			if game != nil {
+				for _, player := range game.Players {
+					env.Debugf("Checking player %#v", player)
+					if human, ok := player.(*Human); ok && human.Client.Id == client.Id {
+						human.Client = client
+						env.Debugf("%s sitting at table %d", human.Client.Name, game.Id)
+					} else {
+						env.Debugf("Not updating the client")
+					}
+				}
+				action = nil
+				continue
+			}
+			return game, nil
+		case game == nil && action.Type != "Sit":
+			if client != nil {
+				client.Tell(env, nil, CreateError(ErrNotSeated))
+			}
+			return nil, ErrNotSeated
+		case action.Type == "Start":
+			env.Debugf("Game is %#v", game)
+			if game.State != StateNew {
+				if client != nil {
+					client.Tell(env, game, CreateError(ErrGameStarted))
+				}
+				return game, ErrGameStarted
+			}
+			for x := range game.Players {
+				if game.Players[x] == nil {
+					game.Players[x] = createAI()
+				}
+			}
+			return game.NextHand(env)
+		case action.Type == "Sit":
+			if action.TableId == 0 { // create a new table/game
+				game = NewGame(4, time.Now().UnixNano(), StandardRules)
+			} else {
+				game = &Game{Id: action.TableId}
+				err := env.Store.GetGame(game)
+				if logError(env, err) {
+					return game, err
+				}
+			}
+			env.Debugf("%s - %d sitting at table %d", client.Name, client.Id, game.Id)
+			openSlot := -1
+			var meHuman *Human
+			for x, player := range game.Players {
+				human, ok := player.(*Human)
+				if ok && human.Client.Id == client.Id {
+					meHuman = human
+					game.Players[x] = nil
+					openSlot = x
+					break
+				}
+				if _, ok := player.(interface{ computer() *AI }); ok {
+					openSlot = x
+				}
+			}
+			if openSlot == -1 {
+				logError(env, ErrGameFull)
+				client.Tell(env, game, CreateError(ErrGameFull))
+				return game, nil
+			}
+			if meHuman == nil {
+				meHuman = &Human{Client: client}
+			}
+			game.Players[openSlot] = game.Players[action.Playerid]
+			game.Players[action.Playerid] = meHuman
+			for _, player := range game.Players {
+				if human, ok := player.(*Human); ok {
+					human.Client.SendTables(env, game)
+				}
+			}
+			var err error
+			game, err = game.ProcessAction(env, nil, nil) // save it to the GameStore
+			logError(env, err)
+			client.TableId = game.Id
+			err = env.Store.PutClient(client)
+			logError(env, err)
+			return game, err
+		case game.State == StateRedeal && (action.Playerid != game.Next || (action.Type != "Redeal" && action.Type != "Keep")):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StateRedeal && action.Type == "Keep":
+			action = game.askRedeal(env, game.Next)
+			continue
+		case game.State == StateRedeal:
+			game.recordRedeal(game.Next)
+			game.Broadcast(env, action, game.Next)
+			game.recordScore(env, false)
+			game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Player %d called for a redeal, player %d deals again", game.Next, game.Dealer)))
+			return game.NextHand(env)
+		case game.State == StateBid && (action.Type != "Bid" || action.Playerid != game.Next):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StateBid:
+			rules := game.rules()
+			if action.Bid != 0 && action.Bid < game.HighBid+rules.Raise() {
+				game.reject(env, sender, game.Next, fmt.Errorf("%w, %d has to be at least %d", ErrBidTooLow, action.Bid, game.HighBid+rules.Raise()))
+				action = game.Players[game.Next].Tell(env, game, CreateBid(game.HighBid, game.Next))
+				continue
+			}
+			if len(game.Passed) != len(game.Players) { // stored before the auction went around more than once
+				game.Passed = make([]bool, len(game.Players))
+			}
+			game.recordBid(action.Bid)
+			game.Broadcast(env, action, game.Next)
+			if action.Bid == 0 {
+				game.Passed[game.Next] = true
+			} else {
+				game.HighBid = action.Bid
+				game.HighPlayer = game.Next
+			}
+			remaining := 0
+			for _, passed := range game.Passed {
+				if !passed {
+					remaining++
+				}
+			}
+			opened := game.HighBid > rules.MinimumBid
+			switch {
+			case remaining == 0: // everyone passed and the dealer isn't stuck
+				game.recordScore(env, false)
+				game.nextDealer()
+				game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Everyone passed, player %d deals", game.Dealer)))
+				return game.NextHand(env)
+			case remaining == 1 && !opened && rules.DealerStuck && !game.Passed[game.Dealer]: // dealer was stuck, tell everyone
+				game.HighPlayer = game.Dealer
+				game.Broadcast(env, CreateBid(game.HighBid, game.Dealer), game.Dealer)
+			case remaining == 1 && opened: // the bidding is done
+			default:
+				for game.Next = game.inc(); game.Passed[game.Next]; game.Next = game.inc() {
+				}
+				action = game.Players[game.Next].Tell(env, game, CreateBid(game.HighBid, game.Next))
+				continue
+			}
+			game.State = StateTrump
+			game.Next = game.HighPlayer
+			action = game.Players[game.HighPlayer].Tell(env, game, CreateTrump(NASuit, game.HighPlayer))
+			continue
+		case game.State == StateTrump && (action.Playerid != game.HighPlayer || action.Type != "Throwin" && action.Type != "Moon" && action.Type != "Trump"):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StateTrump:
+			switch action.Type {
+			case "Throwin":
+				game.HandsPlayed++
+				game.recordTrump(NASuit)
+				game.Broadcast(env, action, action.Playerid)
+				game.Score[game.HighPlayer%game.teams()] -= int16(game.HighBid)
+				game.recordScore(env, false)
+				game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Player %d threw in! Scores are now %s, played %d hands", action.Playerid, game.scores(), game.HandsPlayed)))
+				//Log(4, "Scores are now Team0 = %d to Team1 = %d, played %d hands", game.Score[0], game.Score[1], game.HandsPlayed)
+				game.BroadcastAll(env, CreateScore(game.Score, false, false))
+				game.nextDealer()
+				//Log(4, "-----------------------------------------------------------------------------")
+				return game.NextHand(env)
+			case "Moon", "Trump":
+				if action.Type == "Moon" && game.rules().MoonBonus == 0 {
+					game.reject(env, sender, game.HighPlayer, ErrNoMoon)
+					action = game.Players[game.HighPlayer].Tell(env, game, CreateTrump(NASuit, game.HighPlayer))
+					continue
+				}
+				game.Trump = action.Trump
+				game.Moon = action.Type == "Moon"
+				game.recordTrump(game.Trump)
+				if game.Moon {
+					game.recordMoon()
+				}
+				//Log(4, "Trump is set to %s", game.Trump)
+				game.Broadcast(env, action, game.HighPlayer)
+				if len(game.Widow) > 0 { // the bidder picks it up and buries as many
+					game.BroadcastAll(env, CreateWidow(game.Widow, game.HighPlayer))
+					hand := game.Players[game.HighPlayer].Hand()
+					*hand = append(*hand, game.Widow...)
+					sort.Sort(*hand)
+					game.State = StateBury
+					game.Next = game.HighPlayer
+					action = game.Players[game.Next].Tell(env, game, CreateBury(nil, game.Next))
+					continue
+				}
+				if game.passes() { // the partner passes first
+					game.State = StatePass
+					game.Next = game.partner()
+					action = game.Players[game.Next].Tell(env, game, CreatePass(nil, game.Next))
+					continue
+				}
+				action = game.startPlay(env)
+				continue
+			}
+		case game.State == StateBury && (action.Type != "Bury" || action.Playerid != game.Next):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StateBury:
+			if err := game.bury(action.Hand); err != nil {
+				game.reject(env, sender, game.Next, err)
+				action = game.Players[game.Next].Tell(env, game, CreateBury(nil, game.Next))
+				continue
+			}
+			game.recordBury(action.Hand)
+			game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Player %d buried %d cards", game.Next, len(action.Hand))))
+			if game.passes() {
+				game.State = StatePass
+				game.Next = game.partner()
+				action = game.Players[game.Next].Tell(env, game, CreatePass(nil, game.Next))
+				continue
+			}
+			action = game.startPlay(env)
+			continue
+		case game.State == StatePass && (action.Type != "Pass" || action.Playerid != game.Next):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StatePass:
+			to := game.HighPlayer
+			if game.Next == game.HighPlayer {
+				to = game.partner()
+			}
+			if err := game.pass(action.Hand, game.Next, to); err != nil {
+				game.reject(env, sender, game.Next, err)
+				action = game.Players[game.Next].Tell(env, game, CreatePass(nil, game.Next))
+				continue
+			}
+			game.recordPass(action.Hand)
+			game.Players[to].Tell(env, game, action)
+			for x := range game.Players {
+				if uint8(x) != game.Next && uint8(x) != to {
+					game.Players[x].Tell(env, game, CreateMessage(fmt.Sprintf("Player %d passed %d cards to player %d", game.Next, len(action.Hand), to)))
+				}
+			}
+			if game.Next == game.HighPlayer { // passed back, on to meld
+				action = game.startPlay(env)
+				continue
+			}
+			game.Next = game.HighPlayer
+			action = game.Players[game.Next].Tell(env, game, CreatePass(nil, game.Next))
+			continue
+		case game.State == StateDeclare && (action.Type != "Meld" || action.Playerid != game.Next):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StateDeclare:
+			if err := game.declare(env, game.Next, action.Hand); err != nil {
+				game.reject(env, sender, game.Next, err)
+				action = game.Players[game.Next].Tell(env, game, CreateMeldRequest(game.Trump, game.Next))
+				continue
+			}
+			game.Next = game.inc()
+			if game.Next == game.HighPlayer { // everyone has shown their meld
+				action = game.lead(env)
+				continue
+			}
+			action = game.Players[game.Next].Tell(env, game, CreateMeldRequest(game.Trump, game.Next))
+			continue
+		case game.State == StateMeld && (action.Playerid != game.HighPlayer || action.Type != "Throwin" && action.Type != "Play"):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StateMeld && action.Type == "Throwin":
+			game.HandsPlayed++
+			game.recordConcede()
+			game.Broadcast(env, action, action.Playerid)
+			game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Player %d conceded after seeing the meld", action.Playerid)))
+			teams := game.teams()
+			bidders := game.HighPlayer % teams
+			game.Score[bidders] -= int16(game.HighBid)
+			for team := uint8(0); team < teams; team++ {
+				if team != bidders {
+					game.Score[team] += int16(game.Meld[team])
+				}
+			}
+			return game.finishHand(env)
+		case game.State == StateMeld: // the bidder led instead of conceding
+			game.State = StatePlay
+			continue
+		case game.State == StatePlay && (action.Type != "Play" || action.Playerid != game.Next):
+			game.reject(env, sender, action.Playerid, game.unexpected(action))
+			action = nil
+			continue
+		case game.State == StatePlay:
+			// TODO: check for throw in
+			err := CheckPlay(action.PlayedCard, game.Trick.winningCard(), game.Trick.leadSuit(), game.Players[game.Next].Hand(), game.Trump, &game.rules().Play)
+			if err == nil && !game.Players[game.Next].Hand().Remove(action.PlayedCard) {
+				err = ErrCardNotInHand
+			}
+			if err == nil {
+				game.Broadcast(env, action, game.Next)
+				game.Trick.Next = game.Next
+				game.Trick.PlayCard(action.PlayedCard, game.Trump)
+				game.recordPlay(action.PlayedCard)
+			} else {
+				game.reject(env, sender, game.Next, err)
+				action = game.Players[game.Next].Tell(env, game, game.playRequest(game.Next))
+				continue
+			}
+			if game.Trick.Plays == uint8(len(game.Players)) {
+				teams := game.teams()
+				game.Counters[game.Trick.WinningPlayer%teams] += game.Trick.counters(game.rules().Counting())
+				game.CountMeld[game.Trick.WinningPlayer%teams] = true
+				game.Next = game.Trick.WinningPlayer
+				game.recordTrick(game.Trick.WinningPlayer)
+				game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Player %d wins trick with %s", game.Trick.WinningPlayer, game.Trick.winningCard())))
+				game.BroadcastAll(env, CreateTrick(game.Trick.WinningPlayer))
+				env.Debugf("Player %d wins trick with %s", game.Trick.WinningPlayer, game.Trick.winningCard())
+				bidders := game.HighPlayer % teams
+				if game.Moon && game.Trick.WinningPlayer%teams != bidders { // missed the moon, the hand is over
+					game.HandsPlayed++
+					game.Score[bidders] -= int16(game.HighBid + game.rules().MoonBonus)
+					for team := uint8(0); team < teams; team++ {
+						if team != bidders && (game.CountMeld[team] || !game.rules().DefendersMustTakeTrick) {
+							game.Score[team] += int16(game.Meld[team] + game.Counters[team])
+						}
+					}
+					game.BroadcastAll(env, CreateMessage(fmt.Sprintf("Player %d took a trick, player %d missed the moon", game.Trick.WinningPlayer, game.HighPlayer)))
+					return game.finishHand(env)
+				}
+				if len(*game.Players[0].Hand()) == 0 {
+					game.Counters[game.Trick.WinningPlayer%teams] += uint16(game.rules().Counting().LastTrick)
+					// end of hand
+					game.HandsPlayed++
+					if game.HighBid <= game.Meld[bidders]+game.Counters[bidders] {
+						game.Score[bidders] += int16(game.Meld[bidders] + game.Counters[bidders])
+					} else {
+						game.Score[bidders] -= int16(game.HighBid)
+					}
+					if game.Moon { // took every trick
+						game.Score[bidders] += int16(game.rules().MoonBonus)
+					}
+					for team := uint8(0); team < teams; team++ {
+						if team != bidders && (game.CountMeld[team] || !game.rules().DefendersMustTakeTrick) {
+							game.Score[team] += int16(game.Meld[team] + game.Counters[team])
+						}
+					}
+					return game.finishHand(env)
+				}
+				game.Trick.reset()
+				action = game.Players[game.Next].Tell(env, game, game.playRequest(game.Next))
+				continue
+			}
+			game.Next = game.inc()
+			action = game.Players[game.Next].Tell(env, game, game.playRequest(game.Next))
+			continue
+		}
+	}
+}
+
+type Client struct {
+	Id        int64
+	Connected bool
+	Name      string
+	TableId   int64
+	Token     string
+}
+
+func (c Client) GetId() string {
+	return strconv.Itoa(int(c.Id))
+}
+
+func (c *Client) SetId(id string) {
+	tmp, _ := strconv.Atoi(id)
+	c.Id = int64(tmp)
+}
+
+func (client *Client) SendTables(env *Env, game *Game) {
+	if client.Name == "" {
+		client.Tell(env, game, CreateName())
+	}
+	if game == nil && client.TableId == 0 {
+		tables, err := env.Store.NewGames(30)
+		if err != ErrNoSuchEntity && logError(env, err) {
+			return
+		}
+		tables = append(tables, NewGame(4, 0, StandardRules))
+		env.Debugf("Sending first table to %d - %s %#v", client.Id, client.Name, tables[0])
+		myTableString, err := json.Marshal(struct{ Type, Tables interface{} }{Type: "Tables", Tables: tables})
+		logError(env, err)
+		logError(env, env.Notifier.Notify(client, myTableString))
+	} else {
+		if game == nil {
+			game = &Game{Id: client.TableId}
+			if logError(env, env.Store.GetGame(game)) {
+				return
+			}
+		}
+		me := 0
+		for x, player := range game.Players {
+			human, ok := player.(*Human)
+			if ok {
+				if human.Client.Id == client.Id {
+					me = x
+					break
+				}
+			}
+		}
+		myTableString, err := json.Marshal(struct{ Type, MyTable, Playerid interface{} }{Type: "MyTable", MyTable: game, Playerid: me})
+		if logError(env, err) {
+			return
+		}
+		env.Debugf("Sending MyTable to %d-%s %#v", client.Id, client.Name, game)
+		logError(env, env.Notifier.Notify(client, myTableString))
+		game.Retell(env)
+	}
+}
+
+func (client *Client) Tell(env *Env, game *Game, action *Action) *Action {
+	if !client.Connected {
+		// client is not connected, can't tell them
+		return nil
+	}
+	actionJson, err := action.MarshalJSON()
+	if logError(env, err) {
+		return nil
+	}
+	logError(env, env.Notifier.Notify(client, actionJson))
+	return nil
+}
+
+// SetConnected records whether the client can be told anything, both on the client and on its seat at its table.
+// Losing the connection is broadcast to the rest of the table, regaining it replays the game to the client.
+func (client *Client) SetConnected(env *Env, connected bool) error {
+	client.Connected = connected
+	if err := env.Store.PutClient(client); err != nil {
+		return err
+	}
+	var game *Game
+	if client.TableId != 0 {
+		game = &Game{Id: client.TableId}
+		err := env.Store.GetGame(game)
+		if err == ErrNoSuchEntity {
+			game = nil
+			client.TableId = 0
+			if err = env.Store.PutClient(client); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	if game == nil {
+		if connected {
+			client.SendTables(env, nil)
+		}
+		return nil
+	}
+	me := -1
+	for x, player := range game.Players {
+		if human, ok := player.(*Human); ok && human.Client.Id == client.Id {
+			human.Client = client
+			me = x
+		}
+	}
+	if me == -1 { // no longer sitting at the table
+		if connected {
+			client.SendTables(env, nil)
+		}
+		return nil
+	}
+	if !connected {
+		game.Broadcast(env, CreateDisconnect(uint8(me)), uint8(me))
+	}
+	if err := env.Store.PutGame(game); err != nil {
+		return err
+	}
+	if connected {
+		client.SendTables(env, game) // retells whoever the game is waiting on
+		if uint8(me) != game.Next {
+			game.RetellPlayer(env, uint8(me))
+		}
+	}
+	return nil
+}
+
+type Player interface {
+	Tell(*Env, *Game, *Action) *Action // returns the response if known immediately
+	Hand() *Hand
+	SetHand(*Env, *Game, Hand, uint8, uint8)
+	PlayerID() uint8
+	Team() uint8
+	MarshalJSON() ([]byte, error)
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore is a GameStore that keeps each Game gob encoded and each Client as JSON in a directory on the local disk
+type FileStore struct {
+	mutex  sync.Mutex
+	dir    string
+	lastId int64
+}
+
+// NewFileStore opens (creating if necessary) a FileStore rooted at dir
+func NewFileStore(dir string) (*FileStore, error) {
+	fs := &FileStore{dir: dir}
+	for _, sub := range []string{"games", "clients", "records"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+		ids, err := fs.ids(sub)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if id > fs.lastId {
+				fs.lastId = id
+			}
+		}
+	}
+	return fs, nil
+}
+
+func (fs *FileStore) path(sub string, id int64) string {
+	return filepath.Join(fs.dir, sub, strconv.FormatInt(id, 10))
+}
+
+// ids lists the entities stored in sub, oldest id first
+func (fs *FileStore) ids(sub string) ([]int64, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(fs.dir, sub))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(infos))
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), ".") {
+			continue // partially written file
+		}
+		id, err := strconv.ParseInt(info.Name(), 10, 64)
+		if err != nil {
+			continue // not ours
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (fs *FileStore) read(sub string, id int64) ([]byte, error) {
+	data, err := ioutil.ReadFile(fs.path(sub, id))
+	if os.IsNotExist(err) {
+		return nil, ErrNoSuchEntity
+	}
+	return data, err
+}
+
+// write replaces the file atomically so a crash never leaves half a game behind
+func (fs *FileStore) write(sub string, id int64, data []byte) error {
+	tmp := filepath.Join(fs.dir, sub, "."+strconv.FormatInt(id, 10))
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.path(sub, id))
+}
+
+func (fs *FileStore) GetGame(game *Game) error {
+	fs.mutex.Lock()
+	data, err := fs.read("games", game.Id)
+	fs.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(game)
+}
+
+func (fs *FileStore) PutGame(game *Game) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if game.Id == 0 {
+		fs.lastId++
+		game.Id = fs.lastId
+	}
+	game.Updated = time.Now()
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(game); err != nil {
+		return err
+	}
+	return fs.write("games", game.Id, data.Bytes())
+}
+
+func (fs *FileStore) DeleteGame(game *Game) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	err := os.Remove(fs.path("games", game.Id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (fs *FileStore) GetClient(client *Client) error {
+	fs.mutex.Lock()
+	data, err := fs.read("clients", client.Id)
+	fs.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, client)
+}
+
+func (fs *FileStore) PutClient(client *Client) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if client.Id == 0 {
+		fs.lastId++
+		client.Id = fs.lastId
+	}
+	data, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+	return fs.write("clients", client.Id, data)
+}
+
+// KeepRecord writes the game's Record to records/<id> so finished games can be replayed
+func (fs *FileStore) KeepRecord(game *Game) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	id := game.Id
+	if id == 0 {
+		fs.lastId++
+		id = fs.lastId
+	}
+	return fs.write("records", id, []byte(game.Record.String()))
+}
+
+func (fs *FileStore) findGames(keep func(*Game) bool) ([]*Game, error) {
+	fs.mutex.Lock()
+	ids, err := fs.ids("games")
+	fs.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	games := make([]*Game, 0)
+	for _, id := range ids {
+		game := &Game{Id: id}
+		err := fs.GetGame(game)
+		if err == ErrNoSuchEntity {
+			continue // deleted while we were looking
+		} else if err != nil {
+			return nil, err
+		}
+		if keep(game) {
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+func (fs *FileStore) NewGames(limit int) ([]*Game, error) {
+	games, err := fs.findGames(func(game *Game) bool { return game.State == StateNew })
+	if len(games) > limit {
+		games = games[:limit]
+	}
+	return games, err
+}
+
+func (fs *FileStore) StaleGames(before time.Time) ([]*Game, error) {
+	return fs.findGames(func(game *Game) bool { return game.Updated.Before(before) })
+}
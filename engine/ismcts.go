@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"time"
+
+	. "github.com/mzimmerman/sdzpinochle"
+)
+
+// DefaultExploration is how much an ISMCTS favors the cards it has tried less unless told otherwise
+const DefaultExploration = 0.7
+
+// ISMCTS is a Player that bids, melds and passes like the AI but picks its plays with information set Monte Carlo tree search.
+// Rather than solving each deal of the unseen cards on its own, every deal the HandTracker allows walks one tree of the plays
+// as the owner sees them, so a card is judged by how it does across all of them instead of in each one it could be told apart in.
+// It searches until it has tried the Strategy's Plays, or until the ThinkTime has passed when it's set, the Samples aren't used.
+type ISMCTS struct {
+	*AI
+	Exploration float64 // the UCT constant, 0 means DefaultExploration
+}
+
+// NewISMCTS creates an ISMCTS that plays using strategy
+func NewISMCTS(strategy Strategy) *ISMCTS {
+	return &ISMCTS{AI: NewAI(strategy)}
+}
+
+func (m *ISMCTS) Tell(env *Env, game *Game, action *Action) *Action {
+	if action.Type != "PlayRequest" || action.Playerid != m.Playerid ||
+		game != nil && game.State == StateMeld && m.concedes(game) {
+		return m.AI.Tell(env, game, action)
+	}
+	start := time.Now()
+	card, amount := m.findCardToPlay(action)
+	if env != nil {
+		env.Debugf("Tried %d plays in %s", amount, time.Now().Sub(start))
+	}
+	action.PlayedCard = card
+	m.HT.Trick.Next = action.Playerid
+	m.HT.PlayCard(card, m.Trump)
+	return CreatePlay(card, m.Playerid)
+}
+
+func (m *ISMCTS) findCardToPlay(action *Action) (Card, uint) {
+	m.HT.Trick.Next = action.Playerid
+	plays := m.Plays
+	if plays == 0 {
+		plays = DefaultPlays
+	}
+	exploration := m.Exploration
+	if exploration == 0 {
+		exploration = DefaultExploration
+	}
+	card, amount, err := ismcts(m.HT, action.Trump, plays, m.ThinkTime, exploration, m.random())
+	if err != nil {
+		Log(m.Playerid, "Playing without searching - %v", err)
+		return m.playWithoutSearch(action), 0
+	}
+	runtime.GC() // like the AI, the tree is garbage now
+	return card, amount
+}
+
+// mctsNode is a card played in the tree of an ISMCTS search, the plays that led to it are the path from the root
+type mctsNode struct {
+	card     Card
+	player   uint8 // who played the card
+	parent   *mctsNode
+	children []*mctsNode
+	visits   uint    // times the card was played on the way down
+	avail    uint    // times the card could have been played on the way down
+	reward   float64 // the share of the counters the player's team took from the root on, summed over the visits
+}
+
+// uct is how much the player wants to play this card next, what it has taken so far plus exploration for how seldom it was tried
+func (n *mctsNode) uct(exploration float64) float64 {
+	return n.reward/float64(n.visits) + exploration*math.Sqrt(math.Log(float64(n.avail))/float64(n.visits))
+}
+
+// ismcts searches the plays from the owner's point of view dealing the cards it can't see again for every walk down the tree,
+// it returns the card played the most from the root with the number of plays tried, or ErrImpossibleDeal.
+func ismcts(ht *HandTracker, trump Suit, plays uint, think time.Duration, exploration float64, r *rand.Rand) (Card, uint, error) {
+	rules := ht.playRules()
+	counting := ht.counting()
+	sampler, err := ht.sampler()
+	if err != nil {
+		return NACard, 0, err
+	}
+	root := &mctsNode{card: NACard}
+	pos := newPosition(sampler.deal(r), ht.Trick)
+	// the owner's hand is the same in every deal
+	if cards := potentialPlays(&pos.Hands[ht.Owner], &pos.Trick, trump, rules, counting); len(cards) == 1 {
+		Log(ht.Owner, "Returning the only legal play of %s", cards[0])
+		return cards[0], 0, nil
+	}
+	end := time.Now().Add(think)
+	count := uint(0)
+	// the first walk always runs, however short think is, so the root has a child to return
+	for ; count < plays && (think == 0 || root.visits == 0 || time.Now().Before(end)); pos = newPosition(sampler.deal(r), ht.Trick) {
+		playCount := ht.PlayCount
+		var taken [2]int
+		play := func(card Card) {
+			if winner, counters, finished := pos.play(card, playCount, trump, counting); finished {
+				taken[winner%2] += counters
+			}
+			playCount++
+			count++
+		}
+		node := root
+		for playCount < 48 { // select with UCT until a card that hasn't been tried, which is added
+			player := pos.Trick.Next
+			legal := potentialPlays(&pos.Hands[player], &pos.Trick, trump, rules, counting)
+			var best *mctsNode
+			var untried Hand
+			for _, card := range legal {
+				var child *mctsNode
+				for _, c := range node.children {
+					if c.card == card {
+						child = c
+						break
+					}
+				}
+				if child == nil {
+					untried = append(untried, card)
+					continue
+				}
+				child.avail++
+				if best == nil || child.uct(exploration) > best.uct(exploration) {
+					best = child
+				}
+			}
+			if len(untried) > 0 {
+				best = &mctsNode{card: untried[r.Intn(len(untried))], player: player, parent: node, avail: 1}
+				node.children = append(node.children, best)
+			}
+			node = best
+			play(node.card)
+			if len(untried) > 0 {
+				break
+			}
+		}
+		for playCount < 48 { // play out the rest of the hand at random
+			legal := potentialPlays(&pos.Hands[pos.Trick.Next], &pos.Trick, trump, rules, counting)
+			play(legal[r.Intn(len(legal))])
+		}
+		total := taken[0] + taken[1]
+		root.visits++
+		for ; node != root; node = node.parent {
+			node.visits++
+			if total == 0 {
+				node.reward += 0.5
+			} else {
+				node.reward += float64(taken[node.player%2]) / float64(total)
+			}
+		}
+	}
+	best := root.children[0]
+	for _, child := range root.children {
+		if child.visits > best.visits {
+			best = child
+		}
+	}
+	Log(ht.Owner, "Returning the most searched play %s with %d of %d visits", best.card, best.visits, root.visits)
+	return best.card, count, nil
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a GameStore that keeps everything in process memory.
+// Games are stored gob encoded so that, like a real datastore, every GetGame returns a fresh copy.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	lastId  int64
+	games   map[int64][]byte
+	clients map[int64]Client
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		games:   make(map[int64][]byte),
+		clients: make(map[int64]Client),
+	}
+}
+
+func (ms *MemoryStore) nextId() int64 {
+	ms.lastId++
+	return ms.lastId
+}
+
+func (ms *MemoryStore) GetGame(game *Game) error {
+	ms.mutex.Lock()
+	data, ok := ms.games[game.Id]
+	ms.mutex.Unlock()
+	if !ok {
+		return ErrNoSuchEntity
+	}
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(game)
+}
+
+func (ms *MemoryStore) PutGame(game *Game) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if game.Id == 0 {
+		game.Id = ms.nextId()
+	}
+	game.Updated = time.Now()
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(game); err != nil {
+		return err
+	}
+	ms.games[game.Id] = data.Bytes()
+	return nil
+}
+
+func (ms *MemoryStore) DeleteGame(game *Game) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.games, game.Id)
+	return nil
+}
+
+func (ms *MemoryStore) GetClient(client *Client) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	stored, ok := ms.clients[client.Id]
+	if !ok {
+		return ErrNoSuchEntity
+	}
+	*client = stored
+	return nil
+}
+
+func (ms *MemoryStore) PutClient(client *Client) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if client.Id == 0 {
+		client.Id = ms.nextId()
+	}
+	ms.clients[client.Id] = *client
+	return nil
+}
+
+// findGames returns every stored game matching keep, oldest id first
+func (ms *MemoryStore) findGames(keep func(*Game) bool) ([]*Game, error) {
+	ms.mutex.Lock()
+	ids := make([]int64, 0, len(ms.games))
+	for id := range ms.games {
+		ids = append(ids, id)
+	}
+	ms.mutex.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	games := make([]*Game, 0)
+	for _, id := range ids {
+		game := &Game{Id: id}
+		err := ms.GetGame(game)
+		if err == ErrNoSuchEntity {
+			continue // deleted while we were looking
+		} else if err != nil {
+			return nil, err
+		}
+		if keep(game) {
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+func (ms *MemoryStore) NewGames(limit int) ([]*Game, error) {
+	games, err := ms.findGames(func(game *Game) bool { return game.State == StateNew })
+	if len(games) > limit {
+		games = games[:limit]
+	}
+	return games, err
+}
+
+func (ms *MemoryStore) StaleGames(before time.Time) ([]*Game, error) {
+	return ms.findGames(func(game *Game) bool { return game.Updated.Before(before) })
+}
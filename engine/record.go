@@ -0,0 +1,681 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	. "github.com/mzimmerman/sdzpinochle"
+)
+
+// Record is everything that happened in a game, enough to replay it through the engine and reach the same scores.
+// It is written as text in the spirit of a chess PGN, see WriteTo.
+type Record struct {
+	Seed  int64
+	Rules Rules
+	Hands []*HandRecord
+	Score []int16 // final score of each team
+}
+
+// HandRecord is one hand of a Record
+type HandRecord struct {
+	Dealer   uint8
+	Dealt    []Hand   // indexed by playerid, as they were dealt
+	Widow    Hand     // left out of the deal, empty unless the Seating has one
+	Bids     []uint16 // in the order they were made starting left of the dealer, 0 is a pass
+	Bidder   uint8
+	Bid      uint16   // 0 when everyone passed and the hand was redealt
+	Trump    Suit     // NASuit when the bidder threw in
+	Misdeal  bool     // the deal didn't count out and was dealt again
+	Called   bool     // the Bidder called for a redeal before the bidding, see Rules.CallRedeal
+	Moon     bool     // the Bidder shot the moon
+	Buried   Hand     // the cards the bidder buried after picking up the Widow
+	Passes   []Hand   // with Rules.PassCards, the partner's pass to the bidder then the bidder's pass back
+	Declared []Hand   // with Rules.DeclareMeld, the cards each player showed as meld indexed by playerid
+	Conceded bool     // the bidder threw in after the meld was shown
+	Meld     []uint16 // indexed by playerid
+	Plays    []Card   // in the order they were played
+	Tricks   []uint8  // the winner of each trick
+	Score    []int16  // each team's score after the hand
+}
+
+// RecordKeeper is given the Record of every game that finishes
+type RecordKeeper interface {
+	KeepRecord(game *Game) error
+}
+
+func (record *Record) current() *HandRecord {
+	if record == nil || len(record.Hands) == 0 {
+		return nil
+	}
+	return record.Hands[len(record.Hands)-1]
+}
+
+func (game *Game) recordDeal(hands []Hand) {
+	if game.Record == nil {
+		return
+	}
+	hr := &HandRecord{
+		Dealer: game.Dealer,
+		Dealt:  make([]Hand, len(game.Players)),
+		Meld:   make([]uint16, len(game.Players)),
+		Widow:  append(Hand(nil), game.Widow...),
+	}
+	for x := range hands { // player Dealer+1 gets the first hand
+		hr.Dealt[(int(game.Dealer)+1+x)%len(game.Players)] = append(Hand(nil), hands[x]...)
+	}
+	game.Record.Hands = append(game.Record.Hands, hr)
+}
+
+func (game *Game) recordMisdeal() {
+	if hr := game.Record.current(); hr != nil {
+		hr.Misdeal = true
+	}
+}
+
+func (game *Game) recordRedeal(playerid uint8) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Bidder = playerid
+		hr.Called = true
+	}
+}
+
+func (game *Game) recordBid(bid uint16) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Bids = append(hr.Bids, bid)
+	}
+}
+
+func (game *Game) recordTrump(trump Suit) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Bidder = game.HighPlayer
+		hr.Bid = game.HighBid
+		hr.Trump = trump
+	}
+}
+
+func (game *Game) recordMoon() {
+	if hr := game.Record.current(); hr != nil {
+		hr.Moon = true
+	}
+}
+
+func (game *Game) recordPass(cards Hand) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Passes = append(hr.Passes, append(Hand(nil), cards...))
+	}
+}
+
+func (game *Game) recordBury(cards Hand) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Buried = append(Hand(nil), cards...)
+	}
+}
+
+func (game *Game) recordConcede() {
+	if hr := game.Record.current(); hr != nil {
+		hr.Conceded = true
+	}
+}
+
+func (game *Game) recordDeclare(playerid uint8, cards Hand) {
+	if hr := game.Record.current(); hr != nil {
+		if hr.Declared == nil {
+			hr.Declared = make([]Hand, len(hr.Dealt))
+		}
+		hr.Declared[playerid] = append(Hand{}, cards...)
+	}
+}
+
+func (game *Game) recordMeld(playerid uint8, meld uint16) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Meld[playerid] = meld
+	}
+}
+
+func (game *Game) recordPlay(card Card) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Plays = append(hr.Plays, card)
+	}
+}
+
+func (game *Game) recordTrick(winner uint8) {
+	if hr := game.Record.current(); hr != nil {
+		hr.Tricks = append(hr.Tricks, winner)
+	}
+}
+
+func (game *Game) recordScore(env *Env, gameOver bool) {
+	if game.Record == nil {
+		return
+	}
+	if hr := game.Record.current(); hr != nil {
+		hr.Score = append([]int16(nil), game.Score...)
+	}
+	if gameOver {
+		game.Record.Score = append([]int16(nil), game.Score...)
+		if env.Records != nil {
+			logError(env, env.Records.KeepRecord(game))
+		}
+	}
+}
+
+func writeScore(score []int16) string {
+	strs := make([]string, len(score))
+	for x := range score {
+		strs[x] = strconv.Itoa(int(score[x]))
+	}
+	return strings.Join(strs, " ")
+}
+
+// WriteTo writes the record as text, a header of tags followed by one block per hand:
+//
+//	[Seed "42"]
+//	[Rules "standard"]
+//	[Score "124 87"]
+//
+//	Hand 1 Dealer 0
+//	Dealt 0 TD TD QD ...
+//	Bids 0 25 0 26
+//	Trump 0 26 S
+//	Meld 12 4 0 6
+//	Trick 0 AS TS 9S QS 0
+//	Score 50 12
+//
+// Trick lists the leader, the cards in the order they were played and the winner.  Throwin 0 26 replaces Trump when the bidder threw in,
+// Moon 0 26 S when the bidder shot the moon and Redeal when everyone passed, Redeal 2 when player 2 called for one and Misdeal when the deal didn't count out.
+// A Widow line follows the Dealt lines when the Seating leaves cards out of the deal
+// and a Bury line follows Trump with the cards the bidder buried after picking it up.
+// Pass lines follow Trump with the partner's cards and then the bidder's when the Rules pass.  Declare lines list the cards each player showed before Meld when the Rules declare meld.  Concede follows Meld when the bidder threw in after the meld.
+// Rules that aren't one of the RulePresets are spelled out in a RuleValues tag after the Rules tag, like [RuleValues "MinimumBid=25 Meld.Run=15"].
+func (record *Record) WriteTo(w io.Writer) (n int64, err error) {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "[Seed \"%d\"]\n", record.Seed)
+	fmt.Fprintf(&buf, "[Rules \"%s\"]\n", record.Rules.Name)
+	if preset, ok := RulePresets[record.Rules.Name]; !ok || !reflect.DeepEqual(preset, record.Rules) {
+		fmt.Fprintf(&buf, "[RuleValues \"%s\"]\n", writeRules(record.Rules))
+	}
+	if record.Score != nil {
+		fmt.Fprintf(&buf, "[Score \"%s\"]\n", writeScore(record.Score))
+	}
+	for x, hr := range record.Hands {
+		fmt.Fprintf(&buf, "\nHand %d Dealer %d\n", x+1, hr.Dealer)
+		for y, hand := range hr.Dealt {
+			fmt.Fprintf(&buf, "Dealt %d", y)
+			for _, card := range hand {
+				fmt.Fprintf(&buf, " %s", card)
+			}
+			buf.WriteString("\n")
+		}
+		if len(hr.Widow) > 0 {
+			buf.WriteString("Widow")
+			for _, card := range hr.Widow {
+				fmt.Fprintf(&buf, " %s", card)
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("Bids")
+		for _, bid := range hr.Bids {
+			fmt.Fprintf(&buf, " %d", bid)
+		}
+		buf.WriteString("\n")
+		if hr.Misdeal {
+			buf.WriteString("Misdeal\n")
+		} else if hr.Called {
+			fmt.Fprintf(&buf, "Redeal %d\n", hr.Bidder)
+		} else if hr.Bid == 0 {
+			buf.WriteString("Redeal\n")
+		} else if hr.Trump == NASuit {
+			fmt.Fprintf(&buf, "Throwin %d %d\n", hr.Bidder, hr.Bid)
+		} else if hr.Moon {
+			fmt.Fprintf(&buf, "Moon %d %d %s\n", hr.Bidder, hr.Bid, hr.Trump)
+		} else {
+			fmt.Fprintf(&buf, "Trump %d %d %s\n", hr.Bidder, hr.Bid, hr.Trump)
+		}
+		if len(hr.Buried) > 0 {
+			buf.WriteString("Bury")
+			for _, card := range hr.Buried {
+				fmt.Fprintf(&buf, " %s", card)
+			}
+			buf.WriteString("\n")
+		}
+		for _, pass := range hr.Passes {
+			buf.WriteString("Pass")
+			for _, card := range pass {
+				fmt.Fprintf(&buf, " %s", card)
+			}
+			buf.WriteString("\n")
+		}
+		for y, hand := range hr.Declared {
+			fmt.Fprintf(&buf, "Declare %d", y)
+			for _, card := range hand {
+				fmt.Fprintf(&buf, " %s", card)
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("Meld")
+		for _, meld := range hr.Meld {
+			fmt.Fprintf(&buf, " %d", meld)
+		}
+		buf.WriteString("\n")
+		if hr.Conceded {
+			buf.WriteString("Concede\n")
+		}
+		leader := hr.Bidder
+		players := len(hr.Dealt)
+		for y, winner := range hr.Tricks {
+			fmt.Fprintf(&buf, "Trick %d", leader)
+			for z := y * players; z < (y+1)*players && z < len(hr.Plays); z++ {
+				fmt.Fprintf(&buf, " %s", hr.Plays[z])
+			}
+			fmt.Fprintf(&buf, " %d\n", winner)
+			leader = winner
+		}
+		if hr.Score != nil {
+			fmt.Fprintf(&buf, "Score %s\n", writeScore(hr.Score))
+		}
+	}
+	written, err := io.WriteString(w, buf.String())
+	return int64(written), err
+}
+
+func (record *Record) String() string {
+	var buf strings.Builder
+	record.WriteTo(&buf)
+	return buf.String()
+}
+
+// writeRules lists every rule that isn't zero as name=value, with the meld values named like Meld.Run
+func writeRules(rules Rules) string {
+	var values []string
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for x := 0; x < v.NumField(); x++ {
+			name := prefix + v.Type().Field(x).Name
+			field := v.Field(x)
+			switch {
+			case name == "Name" || field.IsZero():
+			case field.Kind() == reflect.Struct:
+				walk(name+".", field)
+			case field.Kind() == reflect.Bool:
+				values = append(values, name+"="+strconv.FormatBool(field.Bool()))
+			case field.CanUint():
+				values = append(values, name+"="+strconv.FormatUint(field.Uint(), 10))
+			case field.CanInt():
+				values = append(values, name+"="+strconv.FormatInt(field.Int(), 10))
+			}
+		}
+	}
+	walk("", reflect.ValueOf(rules))
+	return strings.Join(values, " ")
+}
+
+// parseRules reads the rules written by writeRules, every rule that isn't listed is zero
+func parseRules(name string, values []string) (Rules, error) {
+	rules := Rules{Name: name}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return rules, fmt.Errorf("rule %s is not name=value", value)
+		}
+		field := reflect.ValueOf(&rules).Elem()
+		for _, part := range strings.Split(kv[0], ".") {
+			if field.Kind() != reflect.Struct || part == "Name" {
+				field = reflect.Value{}
+				break
+			}
+			if field = field.FieldByName(part); !field.IsValid() {
+				break
+			}
+		}
+		var err error
+		switch {
+		case !field.IsValid():
+			err = fmt.Errorf("unknown rule %s", kv[0])
+		case field.Kind() == reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(kv[1])
+			field.SetBool(b)
+		case field.CanUint():
+			var u uint64
+			u, err = strconv.ParseUint(kv[1], 10, field.Type().Bits())
+			field.SetUint(u)
+		case field.CanInt():
+			var i int64
+			i, err = strconv.ParseInt(kv[1], 10, field.Type().Bits())
+			field.SetInt(i)
+		default:
+			err = fmt.Errorf("unknown rule %s", kv[0])
+		}
+		if err != nil {
+			return rules, err
+		}
+	}
+	return rules, nil
+}
+
+func parseCard(str string) (card Card, err error) {
+	err = card.UnmarshalJSON([]byte(strconv.Quote(str)))
+	return
+}
+
+func parseUint8s(fields []string) ([]uint8, error) {
+	values := make([]uint8, len(fields))
+	for x := range fields {
+		value, err := strconv.ParseUint(fields[x], 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		values[x] = uint8(value)
+	}
+	return values, nil
+}
+
+func parseUint16s(fields []string) ([]uint16, error) {
+	values := make([]uint16, len(fields))
+	for x := range fields {
+		value, err := strconv.ParseUint(fields[x], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		values[x] = uint16(value)
+	}
+	return values, nil
+}
+
+func parseScore(fields []string) ([]int16, error) {
+	score := make([]int16, len(fields))
+	for x := range fields {
+		value, err := strconv.ParseInt(fields[x], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		score[x] = int16(value)
+	}
+	return score, nil
+}
+
+// ParseRecord reads a Record written by WriteTo
+func ParseRecord(r io.Reader) (*Record, error) {
+	record := &Record{Rules: StandardRules} // records from before Rules were kept were all standard
+	unknownRules := false                   // named rules that need a RuleValues tag
+	var hr *HandRecord
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "\"]") {
+				return nil, fmt.Errorf("line %d: malformed tag %s", line, text)
+			}
+			tag := strings.SplitN(strings.TrimSuffix(text[1:], "\"]"), " \"", 2)
+			if len(tag) != 2 {
+				return nil, fmt.Errorf("line %d: malformed tag %s", line, text)
+			}
+			var err error
+			switch tag[0] {
+			case "Seed":
+				record.Seed, err = strconv.ParseInt(tag[1], 10, 64)
+			case "Rules":
+				rules, ok := RulePresets[tag[1]]
+				if !ok {
+					rules = Rules{Name: tag[1]}
+				}
+				unknownRules = !ok
+				record.Rules = rules
+			case "RuleValues":
+				record.Rules, err = parseRules(record.Rules.Name, strings.Fields(tag[1]))
+				unknownRules = false
+			case "Score":
+				record.Score, err = parseScore(strings.Fields(tag[1]))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			continue
+		}
+		fields := strings.Fields(text)
+		if fields[0] != "Hand" && hr == nil {
+			return nil, fmt.Errorf("line %d: %s before the first Hand", line, fields[0])
+		}
+		var err error
+		switch fields[0] {
+		case "Hand":
+			if len(fields) != 4 || fields[2] != "Dealer" {
+				return nil, fmt.Errorf("line %d: expected Hand n Dealer n", line)
+			}
+			hr = new(HandRecord)
+			var dealer []uint8
+			if dealer, err = parseUint8s(fields[3:]); err == nil {
+				hr.Dealer = dealer[0]
+			}
+			record.Hands = append(record.Hands, hr)
+		case "Dealt", "Declare":
+			var playerid []uint8
+			if len(fields) < 2 {
+				err = fmt.Errorf("expected %s playerid cards...", fields[0])
+			} else if playerid, err = parseUint8s(fields[1:2]); err == nil {
+				hand := make(Hand, len(fields)-2)
+				for x := range hand {
+					if hand[x], err = parseCard(fields[x+2]); err != nil {
+						break
+					}
+				}
+				hands := &hr.Dealt
+				if fields[0] == "Declare" {
+					hands = &hr.Declared
+				}
+				for len(*hands) <= int(playerid[0]) {
+					*hands = append(*hands, nil)
+				}
+				(*hands)[playerid[0]] = hand
+			}
+		case "Widow", "Bury", "Pass":
+			cards := make(Hand, len(fields)-1)
+			for x := range cards {
+				if cards[x], err = parseCard(fields[x+1]); err != nil {
+					break
+				}
+			}
+			switch fields[0] {
+			case "Widow":
+				hr.Widow = cards
+			case "Bury":
+				hr.Buried = cards
+			default:
+				hr.Passes = append(hr.Passes, cards)
+			}
+		case "Bids":
+			if len(fields) > 1 { // nobody bid when a redeal was called
+				hr.Bids, err = parseUint16s(fields[1:])
+			}
+		case "Trump", "Throwin", "Moon":
+			var bidder []uint8
+			var bid []uint16
+			if len(fields) < 3 {
+				err = fmt.Errorf("expected %s bidder bid", fields[0])
+				break
+			}
+			if bidder, err = parseUint8s(fields[1:2]); err != nil {
+				break
+			}
+			if bid, err = parseUint16s(fields[2:3]); err == nil {
+				hr.Bidder, hr.Bid = bidder[0], bid[0]
+				hr.Trump = NASuit
+				hr.Moon = fields[0] == "Moon"
+				if fields[0] != "Throwin" {
+					if len(fields) != 4 {
+						err = fmt.Errorf("expected %s bidder bid suit", fields[0])
+					} else {
+						err = hr.Trump.UnmarshalJSON([]byte(strconv.Quote(fields[3])))
+					}
+				}
+			}
+		case "Redeal":
+			hr.Trump = NASuit
+			if len(fields) > 1 {
+				var caller []uint8
+				if caller, err = parseUint8s(fields[1:2]); err == nil {
+					hr.Bidder, hr.Called = caller[0], true
+				}
+			}
+		case "Misdeal":
+			hr.Trump, hr.Misdeal = NASuit, true
+		case "Concede":
+			hr.Conceded = true
+		case "Meld":
+			hr.Meld, err = parseUint16s(fields[1:])
+		case "Trick":
+			var winner []uint8
+			if len(fields) < 3 {
+				err = errors.New("expected Trick leader cards... winner")
+			} else if winner, err = parseUint8s(fields[len(fields)-1:]); err == nil {
+				for _, str := range fields[2 : len(fields)-1] {
+					var card Card
+					if card, err = parseCard(str); err != nil {
+						break
+					}
+					hr.Plays = append(hr.Plays, card)
+				}
+				hr.Tricks = append(hr.Tricks, winner[0])
+			}
+		case "Score":
+			hr.Score, err = parseScore(fields[1:])
+		default:
+			err = fmt.Errorf("unknown entry %s", fields[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if unknownRules {
+		return nil, fmt.Errorf("unknown rules %s", record.Rules.Name)
+	}
+	return record, scanner.Err()
+}
+
+// replayer answers the engine's requests with what was recorded, reading how far the replay has got from the replayed game's own Record
+type replayer struct {
+	RealHand *Hand
+	PlayerImpl
+	original *Record
+	asked    *int // the play index last asked for, to catch a recorded play the engine refuses
+}
+
+func (r *replayer) MarshalJSON() ([]byte, error) {
+	return []byte(`"Replay"`), nil
+}
+
+func (r *replayer) Hand() *Hand {
+	return r.RealHand
+}
+
+func (r *replayer) SetHand(env *Env, game *Game, h Hand, dealer, playerid uint8) {
+	hand := make(Hand, len(h))
+	copy(hand, h)
+	r.RealHand = &hand
+	r.Playerid = playerid
+}
+
+// next returns the original's hand that matches the one being replayed and the replay's copy of it
+func (r *replayer) next(game *Game) (original, replay *HandRecord) {
+	index := len(game.Record.Hands) - 1
+	if index < 0 || index >= len(r.original.Hands) {
+		return nil, nil
+	}
+	return r.original.Hands[index], game.Record.Hands[index]
+}
+
+func (r *replayer) Tell(env *Env, game *Game, action *Action) *Action {
+	if action.Playerid != r.Playerid {
+		return nil
+	}
+	original, replay := r.next(game)
+	if original == nil {
+		return nil // ran out of record, the game will stop waiting on us
+	}
+	switch action.Type {
+	case "RedealRequest":
+		if original.Called && original.Bidder == r.Playerid {
+			return CreateRedeal(r.Playerid)
+		}
+		return CreateKeep(r.Playerid)
+	case "MeldRequest":
+		if int(r.Playerid) < len(original.Declared) {
+			return CreateMeld(original.Declared[r.Playerid], 0, r.Playerid)
+		}
+	case "Bid":
+		if len(replay.Bids) < len(original.Bids) {
+			return CreateBid(original.Bids[len(replay.Bids)], r.Playerid)
+		}
+	case "Trump":
+		if action.Trump != NASuit {
+			return nil // someone telling us trump, not asking for it
+		}
+		if original.Trump == NASuit {
+			return CreateThrowin(r.Playerid)
+		}
+		if original.Moon {
+			return CreateMoon(original.Trump, r.Playerid)
+		}
+		return CreateTrump(original.Trump, r.Playerid)
+	case "Bury":
+		if len(action.Hand) == 0 {
+			return CreateBury(original.Buried, r.Playerid)
+		}
+	case "Pass":
+		if len(action.Hand) == 0 && len(replay.Passes) < len(original.Passes) {
+			return CreatePass(original.Passes[len(replay.Passes)], r.Playerid)
+		}
+	case "PlayRequest":
+		if original.Conceded {
+			return CreateThrowin(r.Playerid)
+		}
+		index := len(replay.Plays)
+		if index < len(original.Plays) && index != *r.asked {
+			*r.asked = index
+			return CreatePlay(original.Plays[index], r.Playerid)
+		}
+	}
+	return nil
+}
+
+// Replay plays record back through the engine and returns an error unless it reaches the same deals, meld, tricks and scores
+func Replay(env *Env, record *Record) error {
+	if len(record.Hands) == 0 || len(record.Hands[0].Dealt) == 0 {
+		return errors.New("record has no hands")
+	}
+	replayEnv := *env
+	replayEnv.Records = nil // the original was already kept
+	game := NewGame(len(record.Hands[0].Dealt), record.Seed, record.Rules)
+	game.Dealer = record.Hands[0].Dealer
+	asked := -1
+	for x := range game.Players {
+		game.Players[x] = &replayer{original: record, asked: &asked}
+	}
+	unfinished, err := game.NextHand(&replayEnv)
+	if err != nil {
+		return err
+	}
+	for x, hr := range record.Hands {
+		if x >= len(game.Record.Hands) {
+			return fmt.Errorf("replay ended after %d hands, the record has %d", len(game.Record.Hands), len(record.Hands))
+		}
+		if !reflect.DeepEqual(hr, game.Record.Hands[x]) {
+			return fmt.Errorf("hand %d replayed differently, recorded\n%s\nreplayed\n%s", x+1, &Record{Hands: []*HandRecord{hr}}, &Record{Hands: game.Record.Hands[x : x+1]})
+		}
+	}
+	if unfinished != nil || len(game.Record.Hands) != len(record.Hands) {
+		return fmt.Errorf("replay stopped in hand %d, the record has %d", len(game.Record.Hands), len(record.Hands))
+	}
+	if !reflect.DeepEqual(record.Score, game.Record.Score) {
+		return fmt.Errorf("replay finished %v, the record finished %v", game.Record.Score, record.Score)
+	}
+	return nil
+}
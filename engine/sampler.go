@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+
+	. "github.com/mzimmerman/sdzpinochle"
+)
+
+// ErrImpossibleDeal is returned by Deal when no deal of the unseen cards fits what the HandTracker knows
+var ErrImpossibleDeal = errors.New("no deal of the unseen cards fits what the HandTracker knows")
+
+// sampler deals the cards a HandTracker's owner can't see, keeping to the fewest and the most of each card
+// each player could be holding, see bounds, and to how many cards each player has left.  Every deal is as likely as it would be from a shuffled deck,
+// so a player holding both copies of a card is half as likely as the copies being split between two players.
+// It remembers the ways each deal could be finished as it works them out, so it isn't safe to share.
+type sampler struct {
+	known  [4]SmallHand
+	cards  Hand       // the cards with copies no one is known to hold
+	copies []uint8    // how many copies of each of the cards are unseen
+	room   [][4]uint8 // how many more copies of each of the cards each player could hold
+	needs  [4]uint8   // how many unseen cards each player holds
+	ways   map[samplerState]float64
+}
+
+type samplerState struct {
+	card  int // the index in cards of the next card to deal
+	needs [4]uint8
+}
+
+// split is one way to deal the copies of a card, how many each player gets and how many ways the physical cards could go like that
+type split struct {
+	given  [4]uint8
+	weight float64
+}
+
+// Deal deals the cards the owner can't see at random to the players that could hold them and returns every player's hand,
+// the HandTracker isn't changed.
+func (ht *HandTracker) Deal(r *rand.Rand) ([4]*SmallHand, error) {
+	s, err := ht.sampler()
+	if err != nil {
+		return [4]*SmallHand{}, err
+	}
+	return s.deal(r), nil
+}
+
+func (ht *HandTracker) sampler() (*sampler, error) {
+	s := &sampler{ways: make(map[samplerState]float64)}
+	b := ht.bounds()
+	for player := range b.least {
+		known := uint8(0)
+		for card := AS; int8(card) <= AllCards; card++ {
+			known += b.least[player][card]
+		}
+		if known > b.size[player] {
+			return nil, ErrImpossibleDeal
+		}
+		s.needs[player] = b.size[player] - known
+	}
+	for card := AS; int8(card) <= AllCards; card++ {
+		var room [4]uint8
+		sum := ht.PlayedCards[card]
+		for player := range b.least {
+			least, most := b.least[player][card], b.most[player][card]
+			if least > most {
+				return nil, ErrImpossibleDeal
+			}
+			room[player] = most - least
+			for x := uint8(0); x < least; x++ {
+				s.known[player].Append(card)
+			}
+			sum += least
+		}
+		if sum > 2 {
+			return nil, ErrImpossibleDeal
+		}
+		if sum < 2 {
+			s.cards = append(s.cards, card)
+			s.copies = append(s.copies, 2-sum)
+			s.room = append(s.room, room)
+		}
+	}
+	if s.count(0, s.needs) == 0 {
+		return nil, ErrImpossibleDeal
+	}
+	return s, nil
+}
+
+// handSize is how many cards the player has left, one more than the others when it has yet to play to the trick,
+// and more or fewer while the cards passed before play haven't all been passed back
+func (ht *HandTracker) handSize(player uint8) uint8 {
+	left := 48 - ht.PlayCount
+	size := int8(left / 4)
+	if (player+4-ht.Trick.Next)%4 < left%4 {
+		size++
+	}
+	return uint8(size + ht.Passed[player])
+}
+
+// splits are the ways to deal the copies of the card at index i to players that have room for them and still need cards
+func (s *sampler) splits(i int, needs [4]uint8) (splits []split) {
+	for p := range needs {
+		if s.copies[i] == 1 {
+			if s.room[i][p] > 0 && needs[p] > 0 {
+				var given [4]uint8
+				given[p] = 1
+				splits = append(splits, split{given, 1})
+			}
+			continue
+		}
+		if s.room[i][p] > 1 && needs[p] > 1 {
+			var given [4]uint8
+			given[p] = 2
+			splits = append(splits, split{given, 1})
+		}
+		for q := p + 1; q < len(needs); q++ {
+			if s.room[i][p] > 0 && needs[p] > 0 && s.room[i][q] > 0 && needs[q] > 0 {
+				var given [4]uint8
+				given[p], given[q] = 1, 1
+				splits = append(splits, split{given, 2}) // either copy could have gone to either player
+			}
+		}
+	}
+	return
+}
+
+// count is how many ways the cards from index i on could be dealt to fill needs
+func (s *sampler) count(i int, needs [4]uint8) float64 {
+	if i == len(s.cards) {
+		if needs == [4]uint8{} {
+			return 1
+		}
+		return 0
+	}
+	state := samplerState{i, needs}
+	if ways, ok := s.ways[state]; ok {
+		return ways
+	}
+	ways := float64(0)
+	for _, sp := range s.splits(i, needs) {
+		ways += sp.weight * s.count(i+1, sp.rest(needs))
+	}
+	s.ways[state] = ways
+	return ways
+}
+
+// rest is what the players still need after the split
+func (sp split) rest(needs [4]uint8) [4]uint8 {
+	for p := range needs {
+		needs[p] -= sp.given[p]
+	}
+	return needs
+}
+
+// deal picks how to split each card in turn in proportion to the ways the rest of the deal could be finished after it
+func (s *sampler) deal(r *rand.Rand) (sh [4]*SmallHand) {
+	for p := range sh {
+		sh[p] = s.known[p].CopySmallHand()
+	}
+	needs := s.needs
+	for i, card := range s.cards {
+		target := r.Float64() * s.count(i, needs)
+		var chosen split
+		for _, sp := range s.splits(i, needs) {
+			ways := sp.weight * s.count(i+1, sp.rest(needs))
+			if ways == 0 {
+				continue
+			}
+			chosen = sp // the last that could be finished, in case rounding leaves target above 0
+			if target -= ways; target < 0 {
+				break
+			}
+		}
+		for p := range sh {
+			for x := uint8(0); x < chosen.given[p]; x++ {
+				sh[p].Append(card)
+			}
+		}
+		needs = chosen.rest(needs)
+	}
+	return
+}
@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"math"
+	"time"
+
+	. "github.com/mzimmerman/sdzpinochle"
+)
+
+// tableSize bounds the entries a searcher's transposition table holds, it's emptied when it fills
+const tableSize = 1 << 16
+
+const (
+	exact = iota
+	lowerBound
+	upperBound
+)
+
+// position is the state of a hand being played in the search, what everyone has left and the trick on the table.
+// It's the key of the transposition table, so only the cards already played to the trick are kept in Trick.Played.
+type position struct {
+	Hands [4]SmallHand
+	Trick Trick
+}
+
+func newPosition(hands [4]*SmallHand, trick *Trick) (pos position) {
+	for x := range pos.Hands {
+		pos.Hands[x] = *hands[x]
+	}
+	pos.Trick = *trick
+	if pos.Trick.Plays == pos.Trick.size() {
+		pos.Trick = Trick{Next: pos.Trick.Next, Players: pos.Trick.Players}
+	}
+	for x := pos.Trick.Plays; x < 4; x++ { // the players still to play the trick may have cards left from the last one
+		pos.Trick.Played[(pos.Trick.Lead+x)%4] = 0
+	}
+	return
+}
+
+// play plays card for the player whose turn it is, the play made playCount plays into the hand.
+// When it finishes the trick it returns who took it and the counters it was worth, with the last trick's.
+func (pos *position) play(card Card, playCount uint8, trump Suit, counting *CounterValues) (winner uint8, counters int, finished bool) {
+	pos.Hands[pos.Trick.Next].Remove(card)
+	pos.Trick.PlayCard(card, trump)
+	if pos.Trick.Plays != 4 {
+		return
+	}
+	counters = int(pos.Trick.counters(counting))
+	if playCount+1 == 48 {
+		counters += int(counting.LastTrick)
+	}
+	winner = pos.Trick.WinningPlayer
+	pos.Trick = Trick{Next: winner, Players: pos.Trick.Players}
+	return winner, counters, true
+}
+
+type tableEntry struct {
+	depth uint8 // plays searched below the position
+	value int
+	bound uint8 // exact, lowerBound or upperBound
+	best  Card  // the card that did best, searched first the next time around
+}
+
+// searcher plays out deals depth-first with alpha-beta pruning, deepening a trick at a time.
+// The value of a position is from the owner's team, three times the counters taken less those the opponents take,
+// plus what the trump, aces and tens the team still holds are worth less the opponents', see Worth.
+// A searcher isn't safe to share, playHandWithCard gives each of its workers one.
+type searcher struct {
+	owner    uint8
+	trump    Suit
+	rules    *PlayRules
+	counting *CounterValues
+	scale    int
+	end      time.Time // stop deepening once this has passed, when it's set
+	deadline time.Time // when the depth being searched has to stop, end or zero while the first depth is searched
+	limit    uint      // when the depth being searched has to stop by the plays tried
+	count    uint      // plays tried
+	stopped  bool      // the search ran out of plays or time, the depth being searched is thrown away
+	table    map[position]tableEntry
+}
+
+func newSearcher(owner uint8, trump Suit, rules *PlayRules, counting *CounterValues, end time.Time) *searcher {
+	return &searcher{
+		owner:    owner,
+		trump:    trump,
+		rules:    rules,
+		counting: counting,
+		scale:    int(counting.Scale()),
+		end:      end,
+		table:    make(map[position]tableEntry),
+	}
+}
+
+// search returns what each of the cards is worth when played from the root, searched as deep as it could be within plays,
+// and the number of plays it tried.  The first depth, to the end of the trick on the table, is always searched in full.
+func (s *searcher) search(root *PlayWalker, cards Hand, plays uint) ([]int, uint) {
+	pos := newPosition(root.Hands, root.Trick)
+	start := s.count
+	s.limit, s.deadline = ^uint(0), time.Time{}
+	scores := make([]int, len(cards))
+	values := make([]int, len(cards))
+	target := root.PlayCount + 4 - pos.Trick.Plays%4
+	for {
+		if target > 48 {
+			target = 48
+		}
+		for c, card := range cards {
+			child, gained := s.play(pos, card, root.PlayCount)
+			values[c] = gained + s.alphaBeta(child, root.PlayCount+1, target-root.PlayCount-1, math.MinInt32, math.MaxInt32)
+			if s.stopped {
+				break
+			}
+		}
+		if s.stopped {
+			break
+		}
+		copy(scores, values)
+		if target == 48 {
+			break // searched to the end of the hand
+		}
+		target += 4
+		s.limit, s.deadline = start+plays, s.end
+		if s.out() {
+			break
+		}
+	}
+	s.stopped = false
+	return scores, s.count - start
+}
+
+func (s *searcher) out() bool {
+	return s.count >= s.limit || (!s.deadline.IsZero() && time.Now().After(s.deadline))
+}
+
+// play plays card for the player whose turn it is and returns the position afterwards
+// with what the trick, if the card finished it, was worth to the owner's team.
+func (s *searcher) play(pos position, card Card, playCount uint8) (position, int) {
+	winner, counters, finished := pos.play(card, playCount, s.trump, s.counting)
+	if !finished {
+		return pos, 0
+	}
+	if winner%2 == s.owner%2 {
+		return pos, counters * 3
+	}
+	return pos, -counters * 3
+}
+
+// alphaBeta returns what the rest of the hand from pos is worth to the owner's team searching depth plays ahead,
+// or the bound it found outside of alpha and beta.
+func (s *searcher) alphaBeta(pos position, playCount, depth uint8, alpha, beta int) int {
+	if playCount == 48 {
+		return 0
+	}
+	if depth == 0 {
+		return s.material(&pos)
+	}
+	best := NACard
+	if entry, ok := s.table[pos]; ok {
+		best = entry.best
+		if entry.depth >= depth {
+			switch {
+			case entry.bound == exact:
+				return entry.value
+			case entry.bound == lowerBound && entry.value >= beta:
+				return entry.value
+			case entry.bound == upperBound && entry.value <= alpha:
+				return entry.value
+			}
+		}
+	}
+	if s.out() {
+		s.stopped = true
+		return 0
+	}
+	cards := potentialPlays(&pos.Hands[pos.Trick.Next], &pos.Trick, s.trump, s.rules, s.counting)
+	for x := range cards {
+		if cards[x] == best {
+			cards[0], cards[x] = cards[x], cards[0]
+			break
+		}
+	}
+	maximizing := pos.Trick.Next%2 == s.owner%2
+	value := math.MaxInt32
+	if maximizing {
+		value = math.MinInt32
+	}
+	low, high := alpha, beta
+	for _, card := range cards {
+		s.count++
+		child, gained := s.play(pos, card, playCount)
+		worth := gained + s.alphaBeta(child, playCount+1, depth-1, low-gained, high-gained)
+		if s.stopped {
+			return 0
+		}
+		if maximizing && worth > value || !maximizing && worth < value {
+			value = worth
+			best = card
+		}
+		if maximizing && value > low {
+			low = value
+		} else if !maximizing && value < high {
+			high = value
+		}
+		if low >= high {
+			break
+		}
+	}
+	entry := tableEntry{depth: depth, value: value, best: best}
+	switch {
+	case value <= alpha:
+		entry.bound = upperBound
+	case value >= beta:
+		entry.bound = lowerBound
+	}
+	if len(s.table) >= tableSize {
+		s.table = make(map[position]tableEntry)
+	}
+	s.table[pos] = entry
+	return value
+}
+
+// material is what the trump, aces and tens left in the owner team's hands are worth less the opponents',
+// the same amounts Worth takes off for spending them.
+func (s *searcher) material(pos *position) (worth int) {
+	for card := AS; int8(card) <= AllCards; card++ {
+		for x := range pos.Hands {
+			count := int(pos.Hands[x].Count(card)) * int(spendCost(card, s.trump, int16(s.scale)))
+			if uint8(x)%2 == s.owner%2 {
+				worth += count
+			} else {
+				worth -= count
+			}
+		}
+	}
+	return
+}
@@ -6,35 +6,36 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"os"
 	"reflect"
 	"sort"
 	"strconv"
-	"time"
+	"strings"
 )
 
+// LogOutput is where Log writes, set it to ioutil.Discard to quiet the AI
+var LogOutput io.Writer = os.Stdout
+
 func Log(playerid uint8, m string, v ...interface{}) {
 	if playerid == 4 {
-		fmt.Printf("NP - "+m+"\n", v...)
+		fmt.Fprintf(LogOutput, "NP - "+m+"\n", v...)
 	} else {
-		fmt.Printf("P"+strconv.Itoa(int(playerid))+" - "+m+"\n", v...)
+		fmt.Fprintf(LogOutput, "P"+strconv.Itoa(int(playerid))+" - "+m+"\n", v...)
 	}
 }
 
 const (
-	NAFace      Face  = iota
-	Ace         Face  = iota
-	Ten         Face  = iota
-	King        Face  = iota
-	Queen       Face  = iota
-	Jack        Face  = iota
-	Nine        Face  = iota
-	acearound   uint8 = 10
-	kingaround  uint8 = 8
-	queenaround uint8 = 6
-	jackaround  uint8 = 4
-	debugLog          = false
-	AllCards    int8  = 24
+	NAFace   Face = iota
+	Ace      Face = iota
+	Ten      Face = iota
+	King     Face = iota
+	Queen    Face = iota
+	Jack     Face = iota
+	Nine     Face = iota
+	debugLog      = false
+	AllCards int8 = 24
 )
 
 const (
@@ -77,7 +78,6 @@ var Faces [6]Face
 var Suits [4]Suit
 
 func init() {
-	rand.Seed(time.Now().UnixNano())
 	Faces = [6]Face{Ace, Ten, King, Queen, Jack, Nine}
 	Suits = [4]Suit{Spades, Hearts, Clubs, Diamonds}
 }
@@ -86,7 +86,7 @@ type Card int8 // an integer representation of the card
 type Suit int8
 type Face int8
 
-type Deck [48]Card
+type Deck []Card
 type Hand []Card
 type SmallHand [6]byte
 
@@ -212,14 +212,44 @@ func (c Card) Face() Face {
 	return Face((c-1)%6 + 1)
 }
 
-func (d *Deck) Swap(i, j uint8) {
+func (d Deck) Swap(i, j uint8) {
 	d[i], d[j] = d[j], d[i]
 }
 
-func (d *Deck) Shuffle() {
+// Source is a splitmix64 random source, its State is exported so a Game stored between actions picks up where it left off
+type Source struct {
+	State uint64
+}
+
+func NewSource(seed int64) *Source {
+	return &Source{State: uint64(seed)}
+}
+
+// NewRand returns a *rand.Rand drawing from a new Source, the same seed always produces the same numbers
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(NewSource(seed))
+}
+
+func (s *Source) Seed(seed int64) {
+	s.State = uint64(seed)
+}
+
+func (s *Source) Uint64() uint64 {
+	s.State += 0x9e3779b97f4a7c15
+	z := s.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *Source) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (d Deck) Shuffle(r *rand.Rand) {
 	//	http://en.wikipedia.org/wiki/Fisher%E2%80%93Yates_shuffle#The_modern_algorithm
 	for i := len(d) - 1; i >= 1; i-- {
-		if j := rand.Intn(i); i != j {
+		if j := r.Intn(i + 1); i != j {
 			d.Swap(uint8(i), uint8(j))
 		}
 	}
@@ -315,46 +345,118 @@ func (h Hand) Swap(i, j int) {
 	h[i], h[j] = h[j], h[i]
 }
 
-func (h *Hand) Shuffle() {
+func (h *Hand) Shuffle(r *rand.Rand) {
 	//	http://en.wikipedia.org/wiki/Fisher%E2%80%93Yates_shuffle#The_modern_algorithm
 	for i := len(*h) - 1; i >= 1; i-- {
-		if j := rand.Intn(i); i != j {
+		if j := r.Intn(i + 1); i != j {
 			h.Swap(i, j)
 		}
 	}
 }
 
 func (d Deck) Deal() (hands []Hand) {
-	hands = make([]Hand, 4)
-	for x := 0; x < 4; x++ {
-		hands[x] = make([]Card, 12)
+	hands, _ = d.DealTo(4, 0)
+	return
+}
+
+// DealTo deals the deck one card at a time to players, keeping the last widow cards out of the deal
+func (d Deck) DealTo(players, widow int) (hands []Hand, rest Hand) {
+	each := (len(d) - widow) / players
+	hands = make([]Hand, players)
+	for x := 0; x < players; x++ {
+		hands[x] = make([]Card, each)
 	}
-	for y := 0; y < 12; y++ {
-		for x := 0; x < 4; x++ {
-			hands[x][y] = d[y*4+x]
+	for y := 0; y < each; y++ {
+		for x := 0; x < players; x++ {
+			hands[x][y] = d[y*players+x]
 		}
 	}
+	rest = append(Hand(nil), d[each*players:]...)
 	return
 }
 
 func CreateDeck() (deck Deck) {
-	index := 0
+	deck = make(Deck, 0, 48)
+	for x := AS; int8(x) <= AllCards; x++ {
+		deck = append(deck, x, x)
+	}
+	return
+}
+
+// CreateDoubleDeck is the 80 card deck, four of every card without the nines
+func CreateDoubleDeck() (deck Deck) {
+	deck = make(Deck, 0, 80)
 	for x := AS; int8(x) <= AllCards; x++ {
-		deck[index] = x
-		index++
-		deck[index] = x
-		index++
+		if x.Face() != Nine {
+			deck = append(deck, x, x, x, x)
+		}
 	}
 	return
 }
 
+// Seating is how a table is partnered and dealt for its number of players
+type Seating struct {
+	Players uint8
+	Teams   uint8 // players alternate between teams, everyone is on their own when Teams == Players
+	Widow   uint8 // cards left out of the deal, picked up by the bidder who buries as many, they count for the bidder
+	Double  bool  // played with CreateDoubleDeck
+}
+
+// FourHanded is the partnership game the AI was written for
+var FourHanded = Seating{Players: 4, Teams: 2}
+
+// NewSeating seats players with a single deck (3 cutthroat or 4) or a double deck (4, 6 or 8)
+func NewSeating(players uint8, double bool) (Seating, error) {
+	switch {
+	case !double && players == 3:
+		return Seating{Players: 3, Teams: 3, Widow: 3}, nil
+	case !double && players == 4:
+		return FourHanded, nil
+	case double && (players == 4 || players == 6 || players == 8):
+		return Seating{Players: players, Teams: 2, Widow: 80 % players, Double: true}, nil
+	}
+	decks := "single"
+	if double {
+		decks = "double"
+	}
+	return Seating{}, fmt.Errorf("can't seat %d players with a %s deck", players, decks)
+}
+
+// Deck is a new unshuffled deck for the seating
+func (s Seating) Deck() Deck {
+	if s.Double {
+		return CreateDoubleDeck()
+	}
+	return CreateDeck()
+}
+
+// CheckDeal counts the cards that were dealt, everyone has to have the same number and with the widow they have to be the whole deck
+func (s Seating) CheckDeal(hands []Hand, widow Hand) error {
+	deck := s.Deck()
+	if len(hands) != int(s.Players) || len(widow) != int(s.Widow) {
+		return fmt.Errorf("dealt %d hands and a widow of %d for %d players and a widow of %d", len(hands), len(widow), s.Players, s.Widow)
+	}
+	each := (len(deck) - int(s.Widow)) / int(s.Players)
+	dealt := append(Hand(nil), widow...)
+	for x, hand := range hands {
+		if len(hand) != each {
+			return fmt.Errorf("hand %d has %d cards instead of %d", x, len(hand), each)
+		}
+		dealt = append(dealt, hand...)
+	}
+	if !reflect.DeepEqual(dealt.Count(), Hand(deck).Count()) {
+		return errors.New("the cards dealt aren't the deck")
+	}
+	return nil
+}
+
 type Action struct {
 	Type                    string
 	Playerid                uint8
-	Bid                     uint8
+	Bid                     uint16
 	PlayedCard, WinningCard Card
 	Lead, Trump             Suit
-	Amount                  uint8
+	Amount                  uint16
 	Message                 string
 	Hand                    Hand
 	TableId                 int64
@@ -362,6 +464,9 @@ type Action struct {
 	Score                   []int16
 	Dealer                  uint8
 	WinningPlayer           uint8
+	Melds                   Melds  // a Meld's breakdown
+	Code                    string // an Error's RuleError.Code
+	Legal                   Hand   // the cards a PlayRequest allows, see LegalPlays
 }
 
 func (action *Action) String() string {
@@ -383,6 +488,7 @@ func (action *Action) UnmarshalJSON(data []byte) error {
 	action.TableId = victim.TableId
 	//action.Trump =
 	action.Type = victim.Type
+	action.Hand = victim.Hand // a Pass or a declared Meld
 	var buf bytes.Buffer
 	if victim.PlayedCard != "" {
 		buf.WriteString("\"")
@@ -409,12 +515,13 @@ func (action *Action) UnmarshalJSON(data []byte) error {
 type JSONAction struct {
 	Type       string
 	Playerid   uint8
-	Bid        uint8
+	Bid        uint16
 	PlayedCard string
 	Trump      string
-	Amount     uint8
+	Amount     uint16
 	Message    string
 	TableId    int64
+	Hand       Hand
 }
 
 func (action *Action) MarshalJSON() ([]byte, error) {
@@ -462,7 +569,7 @@ func CreateMessage(m string) *Action {
 	return &Action{Type: "Message", Message: m}
 }
 
-func CreateBid(bid, playerid uint8) *Action {
+func CreateBid(bid uint16, playerid uint8) *Action {
 	return &Action{Type: "Bid", Bid: bid, Playerid: playerid}
 }
 
@@ -478,6 +585,11 @@ func CreateTrump(trump Suit, playerid uint8) *Action {
 	return &Action{Type: "Trump", Trump: trump, Playerid: playerid}
 }
 
+// CreateMoon names trump and shoots the moon, see Rules.MoonBonus
+func CreateMoon(trump Suit, playerid uint8) *Action {
+	return &Action{Type: "Moon", Trump: trump, Playerid: playerid}
+}
+
 func CreateTrick(winningPlayer uint8) *Action {
 	return &Action{Type: "Trick", Playerid: winningPlayer}
 }
@@ -486,14 +598,65 @@ func CreateThrowin(playerid uint8) *Action {
 	return &Action{Type: "Throwin", Playerid: playerid}
 }
 
-func CreateMeld(hand Hand, amount, playerid uint8) *Action {
+func CreateMeld(hand Hand, amount uint16, playerid uint8) *Action {
 	return &Action{Type: "Meld", Hand: hand, Amount: amount, Playerid: playerid}
 }
 
+// CreateMeldBreakdown shows the meld playerid scored with each MeldItem that makes it
+func CreateMeldBreakdown(items Melds, playerid uint8) *Action {
+	meld, shown := items.Total()
+	return &Action{Type: "Meld", Hand: shown, Amount: meld, Playerid: playerid, Melds: items}
+}
+
+// CreateMeldRequest asks a player to show their meld with a Meld action, see Rules.DeclareMeld
+func CreateMeldRequest(trump Suit, playerid uint8) *Action {
+	return &Action{Type: "MeldRequest", Trump: trump, Playerid: playerid}
+}
+
+// CreateError tells a player what was wrong with their action, the Code is set when err is a RuleError
+func CreateError(err error) *Action {
+	action := &Action{Type: "Error", Message: err.Error()}
+	var rule *RuleError
+	if errors.As(err, &rule) {
+		action.Code = rule.Code
+	}
+	return action
+}
+
 func CreateDisconnect(playerid uint8) *Action {
 	return &Action{Type: "Disconnect", Playerid: playerid}
 }
 
+// CreatePass asks playerid for the cards to pass when hand is empty, otherwise it hands the cards playerid passed to their receiver
+func CreatePass(hand Hand, playerid uint8) *Action {
+	return &Action{Type: "Pass", Hand: hand, Playerid: playerid}
+}
+
+// CreateWidow shows everyone the widow that playerid, the bidder, picks up
+func CreateWidow(hand Hand, playerid uint8) *Action {
+	return &Action{Type: "Widow", Hand: hand, Playerid: playerid}
+}
+
+// CreateBury asks the bidder for as many cards as the widow had to bury when hand is empty, otherwise it buries the cards
+func CreateBury(hand Hand, playerid uint8) *Action {
+	return &Action{Type: "Bury", Hand: hand, Playerid: playerid}
+}
+
+// CreateRedealRequest asks a player whose hand lets them call for a redeal if they want one, see Rules.CallRedeal
+func CreateRedealRequest(playerid uint8) *Action {
+	return &Action{Type: "RedealRequest", Playerid: playerid}
+}
+
+// CreateRedeal calls for a redeal
+func CreateRedeal(playerid uint8) *Action {
+	return &Action{Type: "Redeal", Playerid: playerid}
+}
+
+// CreateKeep answers a RedealRequest by playing the hand that was dealt
+func CreateKeep(playerid uint8) *Action {
+	return &Action{Type: "Keep", Playerid: playerid}
+}
+
 func CreateDeal(hand Hand, playerid, dealer uint8) *Action {
 	return &Action{Type: "Deal", Hand: hand, Playerid: playerid, Dealer: dealer}
 }
@@ -504,18 +667,26 @@ func CreateScore(score []int16, gameOver, win bool) *Action {
 
 type PlayerImpl struct {
 	Playerid uint8
+	Teams    uint8 // from the Seating, 0 means 2
 }
 
 func (p PlayerImpl) PlayerID() uint8 {
 	return p.Playerid
 }
 
+func (p PlayerImpl) teams() uint8 {
+	if p.Teams == 0 {
+		return 2
+	}
+	return p.Teams
+}
+
 func (p PlayerImpl) Team() uint8 {
-	return p.Playerid % 2
+	return p.Playerid % p.teams()
 }
 
 func (p PlayerImpl) IsPartner(player uint8) bool {
-	return p.Playerid%2 == player%2
+	return p.Playerid%p.teams() == player%p.teams()
 }
 
 // Used to determine if the leader of the trick made a valid play
@@ -528,65 +699,136 @@ func IsCardInHand(card Card, hand Hand) bool {
 	return false
 }
 
-// playedCard, winningCard Card, leadSuit Suit, hand Hand, trump Suit
+// RuleError is an action that breaks the rules, Code is a machine readable name for it sent to clients in an Error action
+type RuleError struct {
+	Code    string
+	Message string
+}
+
+func (e *RuleError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrCardNotInHand    = &RuleError{"CardNotInHand", "you don't have that card"}
+	ErrMustFollowSuit   = &RuleError{"MustFollowSuit", "you have to follow suit"}
+	ErrMustHeadTrick    = &RuleError{"MustHeadTrick", "you have to beat the winning card"}
+	ErrMustTrump        = &RuleError{"MustTrump", "you have to play trump when you can't follow suit"}
+	ErrMustOvertrump    = &RuleError{"MustOvertrump", "you have to beat the winning trump"}
+	ErrNotYourTurn      = &RuleError{"NotYourTurn", "it's not your turn"}
+	ErrUnexpectedAction = &RuleError{"UnexpectedAction", "the game isn't waiting on that"}
+	ErrBidTooLow        = &RuleError{"BidTooLow", "your bid is too low"}
+	ErrNoMoon           = &RuleError{"NoMoon", "these rules don't let the bidder shoot the moon"}
+	ErrPassCount        = &RuleError{"PassCount", "you have to pass the number of cards the rules pass"}
+	ErrBuryCount        = &RuleError{"BuryCount", "you have to bury as many cards as the widow had"}
+	ErrNotMeld          = &RuleError{"NotMeld", "you showed cards that aren't meld"}
+	ErrGameFull         = &RuleError{"GameFull", "the game is full"}
+	ErrGameStarted      = &RuleError{"GameStarted", "the game is already started"}
+	ErrNotSeated        = &RuleError{"NotSeated", "you aren't sitting at a table"}
+)
+
+// ValidPlay checks a play with the StandardRules, see ValidPlayWith
 func ValidPlay(playedCard, winningCard Card, leadSuit Suit, hand *Hand, trump Suit) bool {
+	return ValidPlayWith(playedCard, winningCard, leadSuit, hand, trump, &StandardRules.Play)
+}
+
+// ValidPlayWith is whether the rules allow the play, any card can be led
+func ValidPlayWith(playedCard, winningCard Card, leadSuit Suit, hand *Hand, trump Suit, rules *PlayRules) bool {
 	if winningCard == NACard || leadSuit == NASuit {
 		return true
 	}
-	// hand is sorted
-	// 1 - Have to follow suit
-	// 2 - Can't follow suit, play trump
-	// 3 - Have to win
-	canFollow := false
-	hasTrump := false
-	canWin := false
-	hasCard := false
-	for _, card := range *hand {
-		if card.Suit() == leadSuit {
-			canFollow = true
-		}
-		if card.Suit() == trump {
-			hasTrump = true
-		}
-		if card == playedCard {
-			hasCard = true
-		}
+	legal := LegalPlays(hand, winningCard, leadSuit, trump, rules)
+	return legal.Contains(playedCard)
+}
+
+// CheckPlay returns the RuleError a play breaks, or nil when it's legal.
+// Any card can be led, it's up to the caller to check a lead is in the hand.
+func CheckPlay(playedCard, winningCard Card, leadSuit Suit, hand *Hand, trump Suit, rules *PlayRules) error {
+	if winningCard == NACard || leadSuit == NASuit {
+		return nil
 	}
-	if !hasCard { // you don't have the card in your hand, not allowed to play it, cheater!
-		return false
+	if !hand.Contains(playedCard) { // you don't have the card in your hand, not allowed to play it, cheater!
+		return ErrCardNotInHand
 	}
-	if winningCard == NACard { // nothing to follow so far, so you win!
-		return true
+	legal := LegalPlays(hand, winningCard, leadSuit, trump, rules)
+	switch {
+	case legal.Contains(playedCard):
+		return nil
+	case legal[0].Suit() == leadSuit && playedCard.Suit() != leadSuit:
+		return ErrMustFollowSuit
+	case playedCard.Suit() == leadSuit:
+		return ErrMustHeadTrick
+	case legal[0].Suit() == trump && playedCard.Suit() != trump:
+		return ErrMustTrump
 	}
+	return ErrMustOvertrump
+}
 
-	// have to loop again because we can't set canWin to true if we're playing trump but we can follow a non-trump suit
-	for _, card := range *hand {
-		if canFollow && leadSuit != trump && card.Suit() == trump {
-			continue
-		}
-		if card.Beats(winningCard, trump) {
-			canWin = true
-			break
+// How a card does on a trick, see PlayStatus
+const (
+	Nothing = iota // leading, or neither following suit nor trumping
+	TrumpLose
+	TrumpWin
+	FollowLose
+	FollowWin
+)
+
+// PlayStatus is how card does on a trick led with lead and won so far by winning
+func PlayStatus(card, winning Card, lead, trump Suit) int {
+	suit := card.Suit()
+	switch {
+	case winning == NACard || lead == NASuit:
+		// do nothing, just be the default case
+	case suit == lead && card.Beats(winning, trump):
+		return FollowWin
+	case suit == lead:
+		return FollowLose
+	case suit == trump && card.Beats(winning, trump):
+		return TrumpWin
+	case suit == trump:
+		return TrumpLose
+	}
+	return Nothing
+}
+
+// CardHolder is a hand that can be asked whether it holds a card, like a *Hand or a *SmallHand
+type CardHolder interface {
+	Contains(card Card) bool
+}
+
+// LegalPlays are the cards in hand the rules allow on a trick led with lead and won so far by winning, once each in card order.
+// Every card is legal to lead, when winning is NACard or lead is NASuit.
+//  1. Have to follow suit
+//  2. Can't follow suit, play trump with MustTrump
+//  3. Have to win following suit with MustHead, or trumping over trump with MustOvertrump
+func LegalPlays(hand CardHolder, winning Card, lead, trump Suit, rules *PlayRules) (legal Hand) {
+	var has [FollowWin + 1]bool
+	for card := AS; int8(card) <= AllCards; card++ {
+		if hand.Contains(card) {
+			has[PlayStatus(card, winning, lead, trump)] = true
 		}
 	}
-	if canFollow {
-		if playedCard.Suit() != leadSuit {
-			return false
-		} else if canWin { // we're following suit
-			return playedCard.Beats(winningCard, trump)
-		} else { // we're following suit and we can't win'
+	allowed := func(cardStatus int) bool {
+		switch {
+		case cardStatus == FollowWin:
 			return true
-		}
-	} else if hasTrump {
-		if playedCard.Suit() != trump {
+		case cardStatus == FollowLose:
+			return !rules.MustHead || !has[FollowWin]
+		case has[FollowWin] || has[FollowLose]: // have to follow suit
 			return false
-		} else if canWin { // we're playing trump
-			return playedCard.Beats(winningCard, trump)
-		} else { // we're playing trump but we can't win
+		case cardStatus == TrumpWin:
 			return true
+		case cardStatus == TrumpLose:
+			return !rules.MustOvertrump || !has[TrumpWin]
 		}
-	} // else { // we can't follow suit and we don't have trump - anything's legal
-	return true
+		return !rules.MustTrump || !(has[TrumpWin] || has[TrumpLose])
+	}
+	for card := AS; int8(card) <= AllCards; card++ {
+		if hand.Contains(card) && allowed(PlayStatus(card, winning, lead, trump)) {
+			legal = append(legal, card)
+		}
+	}
+	return
 }
 
 func (h *SmallHand) Contains(card Card) bool {
@@ -704,141 +946,520 @@ func min(a, b uint8) uint8 {
 	return b
 }
 
-func (h Hand) Meld(trump Suit) (meld uint8, result Hand) {
+// MeldValues is what each meld combination is worth
+type MeldValues struct {
+	Dix                 uint16 // each nine of trump
+	Run                 uint16 // A, T, K, Q, J of trump
+	RunWithMarriage     uint16 // a run plus the other king and queen of trump
+	DoubleRun           uint16
+	RoyalMarriage       uint16 // K and Q of trump
+	DoubleRoyalMarriage uint16
+	Marriage            uint16 // K and Q of any other suit
+	DoubleMarriage      uint16
+	AcesAround          uint16 // one of the face in every suit
+	DoubleAcesAround    uint16 // two of the face in every suit
+	KingsAround         uint16
+	DoubleKingsAround   uint16
+	QueensAround        uint16
+	DoubleQueensAround  uint16
+	JacksAround         uint16
+	DoubleJacksAround   uint16
+	Pinochle            uint16 // JD and QS
+	DoublePinochle      uint16
+}
+
+// CounterValues are what the cards taken in tricks and the last trick score
+type CounterValues struct {
+	Ace       uint8
+	Ten       uint8
+	King      uint8
+	Queen     uint8
+	Jack      uint8
+	Nine      uint8
+	LastTrick uint8
+}
+
+var standardCounters = CounterValues{
+	Ace:       1,
+	Ten:       1,
+	King:      1,
+	LastTrick: 1,
+}
+
+// TenCounters make aces, tens and kings and the last trick worth 10
+var TenCounters = CounterValues{
+	Ace:       10,
+	Ten:       10,
+	King:      10,
+	LastTrick: 10,
+}
+
+// ClassicCounters are the old 11, 10, 4, 3 and 2 point cards with 10 for the last trick
+var ClassicCounters = CounterValues{
+	Ace:       11,
+	Ten:       10,
+	King:      4,
+	Queen:     3,
+	Jack:      2,
+	LastTrick: 10,
+}
+
+// CounterPresets are the CounterValues that can be picked by name
+var CounterPresets = map[string]CounterValues{
+	"one":     standardCounters,
+	"ten":     TenCounters,
+	"classic": ClassicCounters,
+}
+
+// Value is what card scores taken in a trick
+func (values *CounterValues) Value(card Card) uint16 {
+	switch card.Face() {
+	case Ace:
+		return uint16(values.Ace)
+	case Ten:
+		return uint16(values.Ten)
+	case King:
+		return uint16(values.King)
+	case Queen:
+		return uint16(values.Queen)
+	case Jack:
+		return uint16(values.Jack)
+	case Nine:
+		return uint16(values.Nine)
+	}
+	return 0
+}
+
+// Scale is about how many times the standard counters a hand's counters add up to, 10 for TenCounters and ClassicCounters
+func (values *CounterValues) Scale() uint16 {
+	total := uint16(values.LastTrick)
+	for _, card := range CreateDeck() {
+		total += values.Value(card)
+	}
+	standard := uint16(len(CreateDeck())/2 + 1) // aces, tens and kings are half the deck
+	if scale := (total + standard/2) / standard; scale > 0 {
+		return scale
+	}
+	return 1
+}
+
+// PlayRules are what a player has to play to a trick, everyone always has to follow suit
+type PlayRules struct {
+	MustHead      bool // following suit, beat the winning card when you can
+	MustTrump     bool // play trump when you can't follow suit
+	MustOvertrump bool // playing trump, beat trump that's already winning the trick when you can
+}
+
+// Rules are the house rules a game is played by
+type Rules struct {
+	Name                   string
+	Meld                   MeldValues
+	Play                   PlayRules
+	Counters               CounterValues
+	MinimumBid             uint16 // the dealer is stuck with it, a bid has to beat it
+	MinimumRaise           uint16 // a bid has to beat the high bid by at least this, 0 means 1
+	GameTarget             int16  // the first team to reach it wins
+	DealerStuck            bool   // when everyone passes the dealer takes it at MinimumBid, otherwise the hand is redealt
+	DefendersMustTakeTrick bool   // the defending team only keeps its meld if it takes a trick
+	DoubleDeck             bool   // deal CreateDoubleDeck, see NewSeating
+	PassCards              uint8  // cards the bidder and a partner trade after trump is named, 0 doesn't pass
+	ConcedeAfterMeld       bool   // the bidder may throw in once everyone's meld is shown, the defenders keep their meld
+	CallRedeal             bool   // before the bidding a player may call for a redeal when Hand.CanRedeal
+	MoonBonus              uint16 // the bidder may shoot the moon with trump, winning this on top of the hand for taking every trick or losing it for losing one, 0 doesn't allow it
+	DeclareMeld            bool   // every player shows their own meld with a Meld action instead of having it found for them, see Hand.Declare
+	MeldPenalty            uint16 // under DeclareMeld a team loses this for every card shown that isn't meld, 0 rejects the declaration instead
+}
+
+var standardMeld = MeldValues{
+	Dix:                 1,
+	Run:                 15,
+	RunWithMarriage:     19,
+	DoubleRun:           150,
+	RoyalMarriage:       4,
+	DoubleRoyalMarriage: 8,
+	Marriage:            2,
+	DoubleMarriage:      4,
+	AcesAround:          10,
+	DoubleAcesAround:    100,
+	KingsAround:         8,
+	DoubleKingsAround:   80,
+	QueensAround:        6,
+	DoubleQueensAround:  60,
+	JacksAround:         4,
+	DoubleJacksAround:   40,
+	Pinochle:            4,
+	DoublePinochle:      30,
+}
+
+var standardPlay = PlayRules{
+	MustHead:      true,
+	MustTrump:     true,
+	MustOvertrump: true,
+}
+
+// StandardRules is how the game has always been played here
+var StandardRules = Rules{
+	Name:                   "standard",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+}
+
+// RedealRules deals again when everyone passes instead of sticking the dealer
+var RedealRules = Rules{
+	Name:                   "redeal",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DefendersMustTakeTrick: true,
+}
+
+// LongRules plays to 150 with a higher opening bid and meld that always counts
+var LongRules = Rules{
+	Name:        "long",
+	Meld:        standardMeld,
+	Play:        standardPlay,
+	Counters:    standardCounters,
+	MinimumBid:  25,
+	GameTarget:  150,
+	DealerStuck: true,
+}
+
+// DoubleDeckRules plays with the 80 card deck, where hands are bigger and so are the bids
+var DoubleDeckRules = Rules{
+	Name:                   "double",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             50,
+	GameTarget:             500,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+	DoubleDeck:             true,
+}
+
+// PassingRules has the bidder's partner pass 3 cards to the bidder, who passes 3 back before meld
+var PassingRules = Rules{
+	Name:                   "passing",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+	PassCards:              3,
+}
+
+// ConcedeRules lets the bidder look at everyone's meld before deciding to play the hand out
+var ConcedeRules = Rules{
+	Name:                   "concede",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+	ConcedeAfterMeld:       true,
+}
+
+// LooseRules only makes everyone follow suit, they don't have to head the trick or trump when they can't follow
+var LooseRules = Rules{
+	Name:                   "loose",
+	Meld:                   standardMeld,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+}
+
+// NinesRules lets a player with five nines, or without an ace or any meld, call for a redeal
+var NinesRules = Rules{
+	Name:                   "nines",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+	CallRedeal:             true,
+}
+
+// MoonRules let the bidder shoot the moon for 50 points
+var MoonRules = Rules{
+	Name:                   "moon",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+	MoonBonus:              50,
+}
+
+// DeclareRules make every player show their own meld, a card shown that isn't meld costs the team 5 points
+var DeclareRules = Rules{
+	Name:                   "declare",
+	Meld:                   standardMeld,
+	Play:                   standardPlay,
+	Counters:               standardCounters,
+	MinimumBid:             20,
+	GameTarget:             120,
+	DealerStuck:            true,
+	DefendersMustTakeTrick: true,
+	DeclareMeld:            true,
+	MeldPenalty:            5,
+}
+
+// RulePresets are the Rules that can be picked by name
+var RulePresets = map[string]Rules{
+	StandardRules.Name:   StandardRules,
+	RedealRules.Name:     RedealRules,
+	LongRules.Name:       LongRules,
+	DoubleDeckRules.Name: DoubleDeckRules,
+	PassingRules.Name:    PassingRules,
+	ConcedeRules.Name:    ConcedeRules,
+	LooseRules.Name:      LooseRules,
+	NinesRules.Name:      NinesRules,
+	MoonRules.Name:       MoonRules,
+	DeclareRules.Name:    DeclareRules,
+}
+
+// Counting is what the cards taken in tricks score, the standard counters for Rules made before there were Counters
+func (r *Rules) Counting() *CounterValues {
+	if r.Counters == (CounterValues{}) {
+		return &standardCounters
+	}
+	return &r.Counters
+}
+
+// ScoredWith is the rules with their cards taken in tricks scored by values, their bids, game target and meld
+// scaled to match so a hand is bid and played the same way
+func (r Rules) ScoredWith(values CounterValues) Rules {
+	from, to := r.Counting().Scale(), values.Scale()
+	scale := func(value uint16) uint16 {
+		return value * to / from
+	}
+	r.MinimumBid = scale(r.MinimumBid)
+	r.MinimumRaise = scale(r.Raise())
+	r.Counters = values
+	r.GameTarget = int16(scale(uint16(r.GameTarget)))
+	r.MoonBonus = scale(r.MoonBonus)
+	r.MeldPenalty = scale(r.MeldPenalty)
+	meld := reflect.ValueOf(&r.Meld).Elem()
+	for x := 0; x < meld.NumField(); x++ {
+		meld.Field(x).SetUint(uint64(scale(uint16(meld.Field(x).Uint()))))
+	}
+	return r
+}
+
+// Raise is the least a bid has to beat the high bid by
+func (r *Rules) Raise() uint16 {
+	if r.MinimumRaise == 0 {
+		return 1
+	}
+	return r.MinimumRaise
+}
+
+// CanRedeal is whether the hand can be thrown back for a redeal under Rules.CallRedeal,
+// it has five or more nines or it has no aces and no meld whatever is trump
+func (h Hand) CanRedeal(values *MeldValues) bool {
+	nines, aces := 0, 0
+	for _, card := range h {
+		switch card.Face() {
+		case Nine:
+			nines++
+		case Ace:
+			aces++
+		}
+	}
+	if nines >= 5 {
+		return true
+	}
+	if aces > 0 {
+		return false
+	}
+	for _, suit := range Suits {
+		if meld, _ := h.MeldWith(suit, values); meld > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Declare breaks down the meld a player declared from the hand, only the declared cards count.
+// It returns false when the hand doesn't hold every declared card, otherwise mistakes are the declared cards that aren't part of the meld.
+func (h Hand) Declare(declared Hand, trump Suit, values *MeldValues) (items Melds, mistakes Hand, ok bool) {
+	held := h.Count()
+	for card, count := range declared.Count() {
+		if count > held[card] {
+			return nil, nil, false
+		}
+	}
+	items = declared.MeldItems(trump, values)
+	_, shown := items.Total()
+	mistakes = append(Hand{}, declared...)
+	for _, card := range shown {
+		mistakes.Remove(card)
+	}
+	return items, mistakes, true
+}
+
+// Meld scores the hand with the StandardRules meld values
+func (h Hand) Meld(trump Suit) (meld uint16, result Hand) {
+	return h.MeldWith(trump, &StandardRules.Meld)
+}
+
+// MeldWith scores the hand with the given meld values, returning the total and the cards that are shown.
+// The total is a uint16 since a double deck hand can meld more than 255.
+func (h Hand) MeldWith(trump Suit, values *MeldValues) (meld uint16, result Hand) {
+	return h.MeldItems(trump, values).Total()
+}
+
+// MeldItem is one combination of meld, like a run in trump or aces around
+type MeldItem struct {
+	Name   string // the MeldValues field it scores, like Run or DoubleAcesAround
+	Suit   Suit   // the suit of a run, marriage or the nines of trump, NASuit for arounds and pinochle
+	Amount uint16
+	Cards  Hand // the cards that make it, a card can be part of more than one item
+}
+
+func (item MeldItem) String() string {
+	if item.Suit == NASuit {
+		return fmt.Sprintf("%s %d", item.Name, item.Amount)
+	}
+	return fmt.Sprintf("%s in %s %d", item.Name, item.Suit, item.Amount)
+}
+
+// Melds is the breakdown of a hand's meld, see Hand.MeldItems
+type Melds []MeldItem
+
+// Total adds up the meld items, returning the total and the cards that are shown for them
+func (m Melds) Total() (meld uint16, result Hand) {
+	show := make(map[Card]uint8)
+	for _, item := range m {
+		meld += item.Amount
+		for card, count := range item.Cards.Count() {
+			show[card] = max(show[card], count)
+		}
+	}
+	result = make(Hand, 0, len(show))
+	for card, amount := range show {
+		for ; amount > 0; amount-- {
+			result = append(result, card)
+		}
+	}
+	sort.Sort(result)
+	return
+}
+
+func (m Melds) String() string {
+	items := make([]string, len(m))
+	for x, item := range m {
+		items[x] = item.String()
+	}
+	return strings.Join(items, " + ")
+}
+
+// MeldItems breaks down the hand's meld into the combinations that score with the given meld values,
+// a combination worth 0 is still listed since its cards are shown
+func (h Hand) MeldItems(trump Suit, values *MeldValues) (items Melds) {
 	// hand does not have to be sorted
 	count := h.Count()
 	if debugLog {
 		fmt.Printf("Count is %v\n", count)
 	}
-	show := make(map[Card]uint8)
-	around := make(map[Face]uint8)
-	for _, value := range Faces {
-		around[value] = 2
+	add := func(name string, suit Suit, amount uint16, cards Hand) {
+		sort.Sort(cards)
+		item := MeldItem{Name: name, Suit: suit, Amount: amount, Cards: cards}
+		if debugLog {
+			fmt.Println(item)
+		}
+		items = append(items, item)
 	}
-	//	fmt.Printf("AroundBefore = %v\n", around)
-	for _, suit := range Suits { // look through each suit
-		switch { // straights & marriages
-		case trump == suit:
-			if debugLog {
-				fmt.Printf("Scoring %d nine(s) in trump %s\n", count[CreateCard(suit, Nine)], trump)
-			}
-			meld += count[CreateCard(suit, Nine)] // 9s in trump
-			show[CreateCard(suit, Nine)] = count[CreateCard(suit, Nine)]
-			switch {
-			// double straight
-			case count[CreateCard(suit, Ace)] == 2 && count[CreateCard(suit, Ten)] == 2 && count[CreateCard(suit, King)] == 2 && count[CreateCard(suit, Queen)] == 2 && count[CreateCard(suit, Jack)] == 2:
-				meld += 150
-				for _, face := range Faces {
-					show[CreateCard(suit, face)] = 2
-				}
-				if debugLog {
-					fmt.Println("DoubleStraight")
-				}
-			// single straight
-			case count[CreateCard(suit, Ace)] >= 1 && count[CreateCard(suit, Ten)] >= 1 && count[CreateCard(suit, King)] >= 1 && count[CreateCard(suit, Queen)] >= 1 && count[CreateCard(suit, Jack)] >= 1:
-				for _, face := range []Face{Ace, Ten, King, Queen, Jack} {
-					show[CreateCard(suit, face)] = max(show[CreateCard(suit, face)], 1)
-				}
-				if count[CreateCard(suit, King)] == 2 && count[CreateCard(suit, Queen)] == 2 {
-					show[CreateCard(suit, King)] = 2
-					show[CreateCard(suit, Queen)] = 2
-					meld += 19
-					if debugLog {
-						fmt.Println("SingleStraightWithExtraMarriage")
-					}
-				} else {
-					if debugLog {
-						fmt.Println("SingleStraight")
-					}
-					meld += 15
-				}
-			case count[CreateCard(suit, King)] == 2 && count[CreateCard(suit, Queen)] == 2:
-				meld += 8
-				show[CreateCard(suit, King)] = 2
-				show[CreateCard(suit, Queen)] = 2
-				if debugLog {
-					fmt.Println("DoubleMarriageInTrump")
-				}
-			case count[CreateCard(suit, King)] >= 1 && count[CreateCard(suit, Queen)] >= 1:
-				meld += 4
-				show[CreateCard(suit, King)] = max(show[CreateCard(suit, King)], 1)
-				show[CreateCard(suit, Queen)] = max(show[CreateCard(suit, Queen)], 1)
-				if debugLog {
-					fmt.Println("SingleMarriageInTrump")
+	// cards are times of each face in the suit, or in every suit for NASuit
+	cards := func(suit Suit, times uint8, faces ...Face) (cards Hand) {
+		suits := []Suit{suit}
+		if suit == NASuit {
+			suits = Suits[:]
+		}
+		for _, face := range faces {
+			for _, suit := range suits {
+				for x := uint8(0); x < times; x++ {
+					cards = append(cards, CreateCard(suit, face))
 				}
 			}
-		case count[CreateCard(suit, King)] == 2 && count[CreateCard(suit, Queen)] == 2:
-			show[CreateCard(suit, King)] = 2
-			show[CreateCard(suit, Queen)] = 2
-			meld += 4
-			if debugLog {
-				fmt.Println("DoubleMarriage")
-			}
-		case count[CreateCard(suit, King)] >= 1 && count[CreateCard(suit, Queen)] >= 1:
-			show[CreateCard(suit, King)] = max(show[CreateCard(suit, King)], 1)
-			show[CreateCard(suit, Queen)] = max(show[CreateCard(suit, Queen)], 1)
-			if debugLog {
-				fmt.Printf("SingleMarriage in %s, show = %v\n", suit, show)
-			}
-			meld += 2
-		}
-		for _, face := range Faces { // looking for "around" meld
-			//						fmt.Printf("Looking for %d in suit %d\n", value, suit)
-			around[face] = min(count[CreateCard(suit, face)], around[face])
 		}
+		return
 	}
-	for _, face := range []Face{Ace, King, Queen, Jack} {
-		if around[face] > 0 {
-			var worth uint8
-			switch face {
-			case Ace:
-				worth = acearound
-			case King:
-				worth = kingaround
-			case Queen:
-				worth = queenaround
-			case Jack:
-				worth = jackaround
-			}
-			if around[face] == 2 {
-				worth *= 10
+	has := func(suit Suit, times uint8, faces ...Face) bool {
+		for _, face := range faces {
+			if count[CreateCard(suit, face)] < times {
+				return false
 			}
-			for _, suit := range Suits {
-				show[CreateCard(suit, face)] = max(show[CreateCard(suit, face)], around[face])
+		}
+		return true
+	}
+	for _, suit := range Suits { // look through each suit
+		switch { // straights & marriages
+		case trump == suit:
+			if nines := count[CreateCard(suit, Nine)]; nines > 0 {
+				add("Dix", suit, uint16(nines)*values.Dix, cards(suit, nines, Nine))
 			}
-			meld += worth
-			if debugLog {
-				fmt.Printf("Around-%d\n", worth)
+			switch {
+			case has(suit, 2, Ace, Ten, King, Queen, Jack):
+				add("DoubleRun", suit, values.DoubleRun, cards(suit, 2, Ace, Ten, King, Queen, Jack))
+			case has(suit, 1, Ace, Ten, Jack) && has(suit, 2, King, Queen):
+				add("RunWithMarriage", suit, values.RunWithMarriage, cards(suit, 1, Ace, Ten, King, King, Queen, Queen, Jack))
+			case has(suit, 1, Ace, Ten, King, Queen, Jack):
+				add("Run", suit, values.Run, cards(suit, 1, Ace, Ten, King, Queen, Jack))
+			case has(suit, 2, King, Queen):
+				add("DoubleRoyalMarriage", suit, values.DoubleRoyalMarriage, cards(suit, 2, King, Queen))
+			case has(suit, 1, King, Queen):
+				add("RoyalMarriage", suit, values.RoyalMarriage, cards(suit, 1, King, Queen))
 			}
+		case has(suit, 2, King, Queen):
+			add("DoubleMarriage", suit, values.DoubleMarriage, cards(suit, 2, King, Queen))
+		case has(suit, 1, King, Queen):
+			add("Marriage", suit, values.Marriage, cards(suit, 1, King, Queen))
 		}
 	}
-	switch { // pinochle
-	case count[CreateCard(Diamonds, Jack)] == 2 && count[CreateCard(Spades, Queen)] == 2:
-		meld += 30
-		show[CreateCard(Spades, Queen)] = 2
-		show[CreateCard(Diamonds, Jack)] = 2
-		if debugLog {
-			fmt.Println("DoubleNochle")
+	for _, around := range []struct {
+		face           Face
+		single, double string
+		value, doubled uint16
+	}{
+		{Ace, "AcesAround", "DoubleAcesAround", values.AcesAround, values.DoubleAcesAround},
+		{King, "KingsAround", "DoubleKingsAround", values.KingsAround, values.DoubleKingsAround},
+		{Queen, "QueensAround", "DoubleQueensAround", values.QueensAround, values.DoubleQueensAround},
+		{Jack, "JacksAround", "DoubleJacksAround", values.JacksAround, values.DoubleJacksAround},
+	} {
+		times := uint8(2)
+		for _, suit := range Suits {
+			times = min(count[CreateCard(suit, around.face)], times)
 		}
-	case count[CreateCard(Diamonds, Jack)] >= 1 && count[CreateCard(Spades, Queen)] >= 1:
-		meld += 4
-		show[CreateCard(Diamonds, Jack)] = max(show[CreateCard(Diamonds, Jack)], 1)
-		show[CreateCard(Spades, Queen)] = max(show[CreateCard(Spades, Queen)], 1)
-		if debugLog {
-			fmt.Println("Nochle")
+		switch times {
+		case 2:
+			add(around.double, NASuit, around.doubled, cards(NASuit, 2, around.face))
+		case 1:
+			add(around.single, NASuit, around.value, cards(NASuit, 1, around.face))
 		}
 	}
-	result = make([]Card, 0, 12)
-	for card, amount := range show {
-		for {
-			if amount > 0 {
-				result = append(result, card)
-				amount--
-			} else {
-				break
-			}
-		}
+	switch { // pinochle
+	case count[JD] >= 2 && count[QS] >= 2:
+		add("DoublePinochle", NASuit, values.DoublePinochle, Hand{JD, JD, QS, QS})
+	case count[JD] >= 1 && count[QS] >= 1:
+		add("Pinochle", NASuit, values.Pinochle, Hand{JD, QS})
 	}
-	sort.Sort(result)
 	return
 }
@@ -39,7 +39,7 @@ func checkForDupes(h []Hand, t *testSuite) bool {
 func fakeDeal(d *Deck) (h []Hand) {
 	h = make([]Hand, 4)
 	for x := 0; x < 4; x++ {
-		h[x] = d[x*12 : x*12+12]
+		h[x] = Hand((*d)[x*12 : x*12+12])
 	}
 	return
 }
@@ -55,13 +55,24 @@ func (t *testSuite) TestSuit() {
 	t.True(Card(AC).Suit() == Clubs)
 }
 
+func (t *testSuite) TestShuffleSeed() {
+	deck1 := CreateDeck()
+	deck2 := CreateDeck()
+	deck1.Shuffle(NewRand(42))
+	deck2.Shuffle(NewRand(42))
+	t.Equal(deck1, deck2)
+	deck2 = CreateDeck()
+	deck2.Shuffle(NewRand(43))
+	t.Not(t.Equal(deck1, deck2))
+}
+
 func (t *testSuite) TestDeal() {
 	deck := CreateDeck()
 	var h []Hand
 	h = fakeDeal(&deck)
 	//	fmt.Println("Deck Created")
 	t.True(checkForDupes(h, t))
-	deck.Shuffle()
+	deck.Shuffle(NewRand(0))
 	//	fmt.Println("Deck Shuffled")
 	h = fakeDeal(&deck)
 	t.True(checkForDupes(h, t))
@@ -223,6 +234,31 @@ func (t *testSuite) TestUnmarshal() {
 	t.Nil(err)
 	t.Equal(action.PlayedCard, Card(AD))
 	t.Equal(action.Trump, Suit(Diamonds))
+	t.Equal(Hand{TD, QD, JD, ND, QC, NC, AH, TH, QH, JH, KS, QS}, action.Hand)
+}
+
+func (t *testSuite) TestCheckPlay() {
+	hand := Hand{JD, QS, ND, TH}
+	t.Nil(CheckPlay(JD, ND, Diamonds, &hand, Diamonds, &StandardRules.Play))
+	t.Equal(ErrCardNotInHand, CheckPlay(QD, ND, Diamonds, &hand, Diamonds, &StandardRules.Play))
+	t.Equal(ErrMustFollowSuit, CheckPlay(QS, ND, Diamonds, &hand, Diamonds, &StandardRules.Play))
+	t.Equal(ErrMustHeadTrick, CheckPlay(ND, TD, Diamonds, &Hand{AD, ND}, Spades, &StandardRules.Play))
+	t.Equal(ErrMustTrump, CheckPlay(TH, AC, Clubs, &hand, Spades, &StandardRules.Play))
+	t.Equal(ErrMustOvertrump, CheckPlay(ND, TD, Clubs, &Hand{AD, ND}, Diamonds, &StandardRules.Play))
+	t.Nil(CheckPlay(ND, TD, Clubs, &Hand{AD, ND}, Diamonds, &LooseRules.Play))
+}
+
+func (t *testSuite) TestLegalPlays() {
+	hand := Hand{AD, ND, JD, QS, TH, TH}
+	t.Equal(Hand{QS, TH, AD, JD, ND}, LegalPlays(&hand, NACard, NASuit, Spades, &StandardRules.Play))
+	t.Equal(Hand{AD}, LegalPlays(&hand, TD, Diamonds, Spades, &StandardRules.Play))
+	t.Equal(Hand{AD, JD, ND}, LegalPlays(&hand, TD, Diamonds, Spades, &LooseRules.Play))
+	t.Equal(Hand{QS}, LegalPlays(&hand, AC, Clubs, Spades, &StandardRules.Play))
+	t.Equal(Hand{QS, TH, AD, JD, ND}, LegalPlays(&hand, AC, Clubs, Spades, &LooseRules.Play))
+	small := new(SmallHand)
+	small.Append(hand...)
+	t.Equal(LegalPlays(&hand, KS, Spades, Diamonds, &StandardRules.Play), LegalPlays(small, KS, Spades, Diamonds, &StandardRules.Play))
+	t.Equal(Hand{QS}, LegalPlays(small, KS, Spades, Diamonds, &StandardRules.Play)) // can't beat the king, still has to follow suit
 }
 
 func (t *testSuite) TestValidPlay() {
@@ -245,6 +281,98 @@ func (t *testSuite) TestValidPlay() {
 	t.True(ValidPlay(ND, NACard, NASuit, &hand, Clubs))
 }
 
+func (t *testSuite) TestValidPlayWith() {
+	loose := &LooseRules.Play
+	hand := Hand{TH, NH, QS, JD, KD}
+	// heading the trick
+	t.False(ValidPlayWith(NH, KH, Hearts, &hand, Diamonds, &StandardRules.Play))
+	t.True(ValidPlayWith(NH, KH, Hearts, &hand, Diamonds, loose))
+	t.False(ValidPlayWith(QS, KH, Hearts, &hand, Diamonds, loose))
+	// trumping when you can't follow
+	t.False(ValidPlayWith(QS, KC, Clubs, &hand, Diamonds, &StandardRules.Play))
+	t.True(ValidPlayWith(QS, KC, Clubs, &hand, Diamonds, loose))
+	t.True(ValidPlayWith(JD, KC, Clubs, &hand, Diamonds, loose))
+	// overtrumping
+	t.False(ValidPlayWith(JD, QD, Clubs, &hand, Diamonds, &StandardRules.Play))
+	t.True(ValidPlayWith(KD, QD, Clubs, &hand, Diamonds, &StandardRules.Play))
+	t.True(ValidPlayWith(JD, QD, Clubs, &hand, Diamonds, &PlayRules{MustTrump: true}))
+	t.False(ValidPlayWith(QS, QD, Clubs, &hand, Diamonds, &PlayRules{MustTrump: true}))
+}
+
+func (t *testSuite) TestCanRedeal() {
+	t.True(Hand{NS, NS, NH, NC, ND, KS, QH, JC, TD, TC, KH, JS}.CanRedeal(&StandardRules.Meld))
+	t.True(Hand{TS, TS, KH, QC, JC, TH, KD, QS, JH, TC, JS, TD}.CanRedeal(&StandardRules.Meld))
+	t.False(Hand{AS, TS, KH, QC, JD, NS, TH, KC, QS, JH, NC, TC}.CanRedeal(&StandardRules.Meld)) // an ace
+	t.False(Hand{TS, TS, KH, QH, JD, NS, TH, KC, QS, JH, NC, TC}.CanRedeal(&StandardRules.Meld)) // a marriage
+}
+
+func (t *testSuite) TestMeldItems() {
+	hand := Hand{AS, TS, KS, KS, QS, QS, JS, NS, AD, AH, AC, JD}
+	items := hand.MeldItems(Spades, &StandardRules.Meld)
+	t.Equal(Melds{
+		{Name: "Dix", Suit: Spades, Amount: 1, Cards: Hand{NS}},
+		{Name: "RunWithMarriage", Suit: Spades, Amount: 19, Cards: Hand{AS, TS, KS, KS, QS, QS, JS}},
+		{Name: "AcesAround", Suit: NASuit, Amount: 10, Cards: Hand{AD, AC, AH, AS}},
+		{Name: "Pinochle", Suit: NASuit, Amount: 4, Cards: Hand{JD, QS}},
+	}, items)
+	meld, shown := items.Total()
+	t.Equal(uint16(34), meld)
+	t.Equal(12, len(shown))
+	t.Equal("Dix in S 1 + RunWithMarriage in S 19 + AcesAround 10 + Pinochle 4", items.String())
+	t.Equal(0, len(Hand{NS, TD}.MeldItems(Hearts, &StandardRules.Meld)))
+}
+
+func (t *testSuite) TestDeclare() {
+	hand := Hand{AS, TS, KS, QS, JS, QS, KH, QH, JD, NC, TC, AD}
+	items, mistakes, ok := hand.Declare(Hand{AS, TS, KS, QS, JS}, Spades, &StandardRules.Meld)
+	t.True(ok)
+	meld, _ := items.Total()
+	t.Equal(uint16(15), meld)
+	t.Equal(0, len(mistakes))
+	items, mistakes, ok = hand.Declare(Hand{KH, QH, NC}, Spades, &StandardRules.Meld)
+	t.True(ok)
+	meld, _ = items.Total()
+	t.Equal(uint16(2), meld)
+	t.Equal(Hand{NC}, mistakes)
+	_, _, ok = hand.Declare(Hand{KH, KH, QH}, Spades, &StandardRules.Meld)
+	t.False(ok) // only holds one KH
+}
+
+func (t *testSuite) TestCheckDeal() {
+	deck := CreateDeck()
+	hands, widow := deck.DealTo(4, 0)
+	t.Nil(FourHanded.CheckDeal(hands, widow))
+	hands[0][0] = hands[2][0] // three of one card and one of another, the deck isn't shuffled
+	t.True(FourHanded.CheckDeal(hands, widow) != nil)
+	hands, widow = deck.DealTo(4, 0)
+	hands[0] = hands[0][1:]
+	t.True(FourHanded.CheckDeal(hands, widow) != nil)
+	hands, widow = CreateDeck().DealTo(3, 3)
+	t.True(FourHanded.CheckDeal(hands, widow) != nil)
+}
+
+func (t *testSuite) TestCounterValues() {
+	t.Equal(uint16(1), StandardRules.Counting().Value(KS))
+	t.Equal(uint16(0), StandardRules.Counting().Value(QS))
+	t.Equal(uint16(3), ClassicCounters.Value(QS))
+	t.Equal(uint16(1), StandardRules.Counting().Scale())
+	t.Equal(uint16(10), TenCounters.Scale())
+	t.Equal(uint16(10), ClassicCounters.Scale())
+	t.Equal(&StandardRules.Counters, (&Rules{}).Counting())
+
+	rules := StandardRules.ScoredWith(TenCounters)
+	t.Equal(uint16(200), rules.MinimumBid)
+	t.Equal(uint16(10), rules.Raise())
+	t.Equal(int16(1200), rules.GameTarget)
+	t.Equal(uint16(150), rules.Meld.Run)
+	t.Equal(uint16(1500), rules.Meld.DoubleRun)
+	meld, _ := Hand{AD, TD, KD, QD, JD, ND}.MeldWith(Diamonds, &rules.Meld)
+	t.Equal(uint16(160), meld)
+	rules = rules.ScoredWith(StandardRules.Counters)
+	rules.MinimumRaise = 0
+	t.Equal(StandardRules, rules)
+}
+
 func (t *testSuite) TestCount() {
 	hand := Hand{JD, QD, KD, AD, JD, JS, QD, KS, AS, TS, JS, TD}
 	count := hand.Count()
@@ -340,3 +468,43 @@ func (t *testSuite) TestMeld() {
 		}
 	}
 }
+
+func (t *testSuite) TestMeldWith() {
+	hand := Hand{AD, AH, AS, AC, KD, KH, KS, KC, QS, QS, JD, JD}
+	sort.Sort(hand)
+	values := StandardRules.Meld
+	meld, _ := hand.MeldWith(Spades, &values)
+	t.Equal(52, int(meld))
+	values.AcesAround = 15
+	values.KingsAround = 10
+	meld, _ = hand.MeldWith(Spades, &values)
+	t.Equal(59, int(meld))
+	meld, _ = hand.Meld(Spades)
+	t.Equal(52, int(meld))
+}
+
+func (t *testSuite) TestSeating() {
+	seating, err := NewSeating(3, false)
+	t.Nil(err)
+	t.Equal(uint8(3), seating.Teams)
+	hands, widow := seating.Deck().DealTo(3, int(seating.Widow))
+	t.Equal(3, len(widow))
+	t.Equal(15, len(hands[2]))
+	seating, err = NewSeating(6, true)
+	t.Nil(err)
+	deck := seating.Deck()
+	t.Equal(80, len(deck))
+	t.False(IsCardInHand(NS, Hand(deck)))
+	hands, widow = deck.DealTo(6, int(seating.Widow))
+	t.Equal(2, len(widow))
+	t.Equal(13, len(hands[5]))
+	_, err = NewSeating(5, true)
+	t.True(err != nil)
+}
+
+func (t *testSuite) TestDoubleDeckMeld() {
+	// a double run, double aces around and a double pinochle from a 20 card double deck hand
+	hand := Hand{AS, AS, TS, TS, KS, KS, QS, QS, JS, JS, AH, AH, AC, AC, AD, AD, JD, JD, TH, TC}
+	meld, _ := hand.Meld(Spades)
+	t.Equal(uint16(280), meld)
+}